@@ -0,0 +1,19 @@
+// Package v1 contains the API types of the observability.kubevishwa.io/v1 group
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupVersion is the group and version of the tracing custom resources
+var GroupVersion = schema.GroupVersion{Group: "observability.kubevishwa.io", Version: "v1"}
+
+// AddToScheme registers the tracing custom resources with a scheme
+func AddToScheme(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(GroupVersion, &TracingConfig{}, &TracingConfigList{}, &TracingDebugSession{}, &TracingDebugSessionList{},
+		&TracingDefaults{}, &TracingDefaultsList{}, &TracingPolicy{}, &TracingPolicyList{})
+	metav1.AddToGroupVersion(scheme, GroupVersion)
+	return nil
+}
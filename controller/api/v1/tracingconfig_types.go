@@ -0,0 +1,666 @@
+package v1
+
+import (
+	"math"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// AnnotationConfigHash is stamped on the pod template of injected workloads. Changing it rolls
+	// the pods, which is what makes them pick up a changed ConfigMap, and lets us tell which running
+	// pods already carry the current configuration.
+	AnnotationConfigHash = "tracing.kubevishwa.io/config-hash"
+
+	// AnnotationInject set to "false" on a workload or its pod template opts it out of every
+	// TracingConfig. AnnotationConfig names the TracingConfig ("name" in the workload's namespace,
+	// or "namespace/name") that injects the workload, whether or not its selector matches.
+	AnnotationInject = "tracing.kubevishwa.io/inject"
+	AnnotationConfig = "tracing.kubevishwa.io/config"
+
+	// DriftPolicyEnforce overwrites manual changes to injected resources, DriftPolicyReportOnly leaves them alone
+	DriftPolicyEnforce    = "Enforce"
+	DriftPolicyReportOnly = "ReportOnly"
+
+	// EnvConflictRespect (default) leaves OTEL_* env vars already set on a container in place and
+	// reports them, EnvConflictOverride removes them so that the rendered values apply, and
+	// EnvConflictFail refuses to inject the workload
+	EnvConflictRespect  = "Respect"
+	EnvConflictOverride = "Override"
+	EnvConflictFail     = "Fail"
+
+	// EnvConflictRespected and EnvConflictOverridden tell how a conflicting env var was resolved
+	EnvConflictRespected  = "Respected"
+	EnvConflictOverridden = "Overridden"
+
+	defaultCAKey = "ca.crt"
+)
+
+// TracingConfig represents our custom resource
+type TracingConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TracingConfigSpec   `json:"spec,omitempty"`
+	Status            TracingConfigStatus `json:"status,omitempty"`
+}
+
+type TracingConfigSpec struct {
+	Enabled           bool                  `json:"enabled"`
+	SamplingRate      float64               `json:"samplingRate,omitempty"`
+	Endpoint          string                `json:"endpoint"`
+	Protocol          string                `json:"protocol,omitempty"`
+	Compression       string                `json:"compression,omitempty"`
+	ServiceName       string                `json:"serviceName"`
+	Namespace         string                `json:"namespace,omitempty"`
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	Selector          *metav1.LabelSelector `json:"selector,omitempty"`
+	Headers           map[string]string     `json:"headers,omitempty"`
+	Attributes        map[string]string     `json:"attributes,omitempty"`
+	ExportTimeout     string                `json:"exportTimeout,omitempty"`
+	BatchTimeout      string                `json:"batchTimeout,omitempty"`
+	MaxBatchSize      int                   `json:"maxBatchSize,omitempty"`
+	TLS               *TracingTLSConfig     `json:"tls,omitempty"`
+	Schedule          *TracingSchedule      `json:"schedule,omitempty"`
+	RolloutStrategy   *RolloutStrategy      `json:"rolloutStrategy,omitempty"`
+	// DriftPolicy is Enforce (default) to revert manual changes to injected resources, or ReportOnly
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+	// AttributesFromLabels and AttributesFromAnnotations map pod label and annotation keys to the
+	// resource attributes they are reported as; an empty attribute name reuses the key
+	AttributesFromLabels      map[string]string `json:"attributesFromLabels,omitempty"`
+	AttributesFromAnnotations map[string]string `json:"attributesFromAnnotations,omitempty"`
+	// Containers limits injection to the named containers. Without it every container is injected
+	// except those in ExcludeContainers and well-known sidecars.
+	Containers        []string `json:"containers,omitempty"`
+	ExcludeContainers []string `json:"excludeContainers,omitempty"`
+	// EnvConflictPolicy is Respect (default), Override or Fail, for OTEL_* env vars the targeted
+	// containers already set themselves
+	EnvConflictPolicy string `json:"envConflictPolicy,omitempty"`
+	// HeadersSecretName names a Secret in each target namespace whose keys are exporter headers,
+	// e.g. API keys; they are merged over Headers
+	HeadersSecretName string `json:"headersSecretName,omitempty"`
+	// RevisionHistoryLimit is how many revisions of the spec are kept, 10 by default
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// RollbackTo restores the spec recorded in a revision; the controller clears it once done
+	RollbackTo *RollbackConfig `json:"rollbackTo,omitempty"`
+	// Budget lets the controller adjust the sampling rate to the span throughput the collector
+	// observes, instead of using SamplingRate as is
+	Budget *SpanBudget `json:"budget,omitempty"`
+}
+
+// SpanBudget caps the span throughput of the services a TracingConfig renders. SamplingRate is
+// the rate to start from.
+type SpanBudget struct {
+	SpansPerSecond float64 `json:"spansPerSecond"`
+	// MinSamplingRate and MaxSamplingRate bound the adjusted rate, 0.01 and 1 by default
+	MinSamplingRate *float64 `json:"minSamplingRate,omitempty"`
+	MaxSamplingRate *float64 `json:"maxSamplingRate,omitempty"`
+	// Interval is the least time between two adjustments, 10m by default. Each adjustment rolls
+	// the pods like any other configuration change.
+	Interval string `json:"interval,omitempty"`
+}
+
+// RollbackConfig names the revision to roll back to; 0 is the revision before the current one
+type RollbackConfig struct {
+	Revision int64 `json:"revision"`
+}
+
+// RolloutStrategy moves workloads to a new configuration revision one batch at a time
+type RolloutStrategy struct {
+	// BatchSize is the number of workloads per step; BatchPercentage is used when it is not set
+	BatchSize       int32 `json:"batchSize,omitempty"`
+	BatchPercentage int32 `json:"batchPercentage,omitempty"`
+	// Pause is waited between steps, once the previous batch is available
+	Pause string `json:"pause,omitempty"`
+	// HaltOnCrashLoop stops the rollout while pods of an updated workload are crash-looping
+	HaltOnCrashLoop bool `json:"haltOnCrashLoop,omitempty"`
+}
+
+// TracingSchedule overrides sampling during recurring time windows
+type TracingSchedule struct {
+	// TimeZone is an IANA time zone name for the cron expressions, UTC by default
+	TimeZone string           `json:"timeZone,omitempty"`
+	Windows  []SamplingWindow `json:"windows"`
+}
+
+// SamplingWindow opens at every activation of Cron and stays open for Duration
+type SamplingWindow struct {
+	Name         string   `json:"name"`
+	Cron         string   `json:"cron"`
+	Duration     string   `json:"duration"`
+	SamplingRate *float64 `json:"samplingRate,omitempty"`
+	Enabled      *bool    `json:"enabled,omitempty"`
+}
+
+// TracingTLSConfig configures transport security between the workload and the OTLP endpoint.
+// The referenced Secrets must live in the target namespace.
+type TracingTLSConfig struct {
+	Insecure             bool   `json:"insecure,omitempty"`
+	CASecretName         string `json:"caSecretName,omitempty"`
+	CAKey                string `json:"caKey,omitempty"`
+	ClientCertSecretName string `json:"clientCertSecretName,omitempty"`
+}
+
+type TracingConfigStatus struct {
+	Phase      string             `json:"phase,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	AppliedAt  *metav1.Time       `json:"appliedAt,omitempty"`
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ActiveWindow names the schedule window currently overriding the spec, if any
+	ActiveWindow           string       `json:"activeWindow,omitempty"`
+	NextScheduleTransition *metav1.Time `json:"nextScheduleTransition,omitempty"`
+
+	// ConfigHash identifies the rendered configuration; pods carrying it are up to date
+	ConfigHash    string           `json:"configHash,omitempty"`
+	WorkloadCount int32            `json:"workloadCount"`
+	ReadyPods     int32            `json:"readyPods"`
+	StalePods     int32            `json:"stalePods"`
+	Workloads     []WorkloadStatus `json:"workloads,omitempty"`
+
+	// TargetNamespaces and ConfigMaps track where the configuration was rendered
+	TargetNamespaces []string             `json:"targetNamespaces,omitempty"`
+	ConfigMaps       []ConfigMapReference `json:"configMaps,omitempty"`
+
+	// FailedWorkloads lists the workloads that could not be injected in the last reconcile
+	FailedWorkloads []WorkloadFailure `json:"failedWorkloads,omitempty"`
+
+	// SkippedWorkloads lists the selected workloads left alone because of their annotations
+	SkippedWorkloads []SkippedWorkload `json:"skippedWorkloads,omitempty"`
+
+	// Rollout reports the progress of the current revision when a rollout strategy is set
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Drift lists the resources that were changed by hand since they were last rendered
+	Drift []DriftReport `json:"drift,omitempty"`
+
+	// ResolvedSpec is the spec after merging in the namespace's TracingDefaults, listed in Defaults;
+	// DefaultedFields tells which TracingDefaults each field taken from them came from
+	ResolvedSpec    *TracingConfigSpec `json:"resolvedSpec,omitempty"`
+	Defaults        []string           `json:"defaults,omitempty"`
+	DefaultedFields []DefaultedField   `json:"defaultedFields,omitempty"`
+
+	// CurrentRevision is the number of the revision recording the current spec
+	CurrentRevision int64 `json:"currentRevision,omitempty"`
+
+	// PolicyViolations lists the settings outside the limits of the TracingPolicies that apply,
+	// and MaxSamplingRate the lowest sampling rate cap among them
+	PolicyViolations []PolicyViolation `json:"policyViolations,omitempty"`
+	MaxSamplingRate  *float64          `json:"maxSamplingRate,omitempty"`
+
+	// Budget reports the sampling rate chosen for spec.budget and how it got there
+	Budget *BudgetStatus `json:"budget,omitempty"`
+}
+
+// BudgetStatus reports the sampling rate the span budget renders
+type BudgetStatus struct {
+	SamplingRate           float64      `json:"samplingRate"`
+	ObservedSpansPerSecond *float64     `json:"observedSpansPerSecond,omitempty"`
+	Message                string       `json:"message,omitempty"`
+	LastAdjusted           *metav1.Time `json:"lastAdjusted,omitempty"`
+	// Decisions lists the latest adjustments, oldest first
+	Decisions []BudgetDecision `json:"decisions,omitempty"`
+}
+
+// BudgetDecision records one adjustment of the sampling rate to the span budget
+type BudgetDecision struct {
+	Time                   metav1.Time `json:"time"`
+	ObservedSpansPerSecond float64     `json:"observedSpansPerSecond"`
+	FromSamplingRate       float64     `json:"fromSamplingRate"`
+	ToSamplingRate         float64     `json:"toSamplingRate"`
+}
+
+// DriftReport describes how a live resource differs from the rendered desired state
+type DriftReport struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Diff      []string `json:"diff"`
+}
+
+// RolloutStatus reports how far a revision has been rolled out
+type RolloutStatus struct {
+	Revision         string       `json:"revision"`
+	Phase            string       `json:"phase"`
+	Message          string       `json:"message,omitempty"`
+	UpdatedWorkloads int32        `json:"updatedWorkloads"`
+	TotalWorkloads   int32        `json:"totalWorkloads"`
+	LastBatchAt      *metav1.Time `json:"lastBatchAt,omitempty"`
+}
+
+// ConfigMapReference points at a ConfigMap rendered for a TracingConfig
+type ConfigMapReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// WorkloadStatus reports how far the configuration has reached the pods of one workload
+type WorkloadStatus struct {
+	Kind                 string   `json:"kind"`
+	Namespace            string   `json:"namespace"`
+	Name                 string   `json:"name"`
+	InjectedContainers   []string `json:"injectedContainers,omitempty"`
+	ConfigHash           string   `json:"configHash,omitempty"`
+	ObservedConfigHashes []string `json:"observedConfigHashes,omitempty"`
+	ReadyPods            int32    `json:"readyPods"`
+	StalePods            int32    `json:"stalePods"`
+	DebugSession         string   `json:"debugSession,omitempty"`
+	// ServiceName is the service name the workload reports, rendered from a serviceName template
+	ServiceName string `json:"serviceName,omitempty"`
+	// EnvConflicts lists the OTEL_* env vars the containers set themselves, and which value wins
+	EnvConflicts []EnvConflict `json:"envConflicts,omitempty"`
+}
+
+// EnvConflict describes an env var set on a container that shadows a rendered value.
+// Values read from another source are described rather than resolved.
+type EnvConflict struct {
+	Container      string `json:"container"`
+	Name           string `json:"name"`
+	Resolution     string `json:"resolution"`
+	EffectiveValue string `json:"effectiveValue"`
+	RenderedValue  string `json:"renderedValue"`
+}
+
+// WorkloadFailure describes why a single workload could not be injected
+type WorkloadFailure struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+}
+
+// SkippedWorkload describes why a selected workload is not injected
+type SkippedWorkload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+}
+
+type TracingConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TracingConfig `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (tc *TracingConfig) DeepCopyObject() runtime.Object {
+	if tc == nil {
+		return nil
+	}
+	out := new(TracingConfig)
+	tc.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tc *TracingConfig) DeepCopyInto(out *TracingConfig) {
+	*out = *tc
+	out.TypeMeta = tc.TypeMeta
+	tc.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	tc.Spec.DeepCopyInto(&out.Spec)
+	tc.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy creates a deep copy of the TracingConfig
+func (tc *TracingConfig) DeepCopy() *TracingConfig {
+	if tc == nil {
+		return nil
+	}
+	out := new(TracingConfig)
+	tc.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tcs *TracingConfigSpec) DeepCopyInto(out *TracingConfigSpec) {
+	*out = *tcs
+	if tcs.Selector != nil {
+		in, out := &tcs.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if tcs.Namespaces != nil {
+		in, out := &tcs.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.NamespaceSelector != nil {
+		in, out := &tcs.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if tcs.Headers != nil {
+		in, out := &tcs.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tcs.Attributes != nil {
+		in, out := &tcs.Attributes, &out.Attributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tcs.AttributesFromLabels != nil {
+		in, out := &tcs.AttributesFromLabels, &out.AttributesFromLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tcs.AttributesFromAnnotations != nil {
+		in, out := &tcs.AttributesFromAnnotations, &out.AttributesFromAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tcs.Containers != nil {
+		in, out := &tcs.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.ExcludeContainers != nil {
+		in, out := &tcs.ExcludeContainers, &out.ExcludeContainers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.TLS != nil {
+		in, out := &tcs.TLS, &out.TLS
+		*out = new(TracingTLSConfig)
+		**out = **in
+	}
+	if tcs.Schedule != nil {
+		in, out := &tcs.Schedule, &out.Schedule
+		*out = new(TracingSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if tcs.RolloutStrategy != nil {
+		in, out := &tcs.RolloutStrategy, &out.RolloutStrategy
+		*out = new(RolloutStrategy)
+		**out = **in
+	}
+	if tcs.RevisionHistoryLimit != nil {
+		in, out := &tcs.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if tcs.RollbackTo != nil {
+		in, out := &tcs.RollbackTo, &out.RollbackTo
+		*out = new(RollbackConfig)
+		**out = **in
+	}
+	if tcs.Budget != nil {
+		in, out := &tcs.Budget, &out.Budget
+		*out = new(SpanBudget)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (sb *SpanBudget) DeepCopyInto(out *SpanBudget) {
+	*out = *sb
+	if sb.MinSamplingRate != nil {
+		in, out := &sb.MinSamplingRate, &out.MinSamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+	if sb.MaxSamplingRate != nil {
+		in, out := &sb.MaxSamplingRate, &out.MaxSamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (ts *TracingSchedule) DeepCopyInto(out *TracingSchedule) {
+	*out = *ts
+	if ts.Windows != nil {
+		in, out := &ts.Windows, &out.Windows
+		*out = make([]SamplingWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (sw *SamplingWindow) DeepCopyInto(out *SamplingWindow) {
+	*out = *sw
+	if sw.SamplingRate != nil {
+		in, out := &sw.SamplingRate, &out.SamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+	if sw.Enabled != nil {
+		in, out := &sw.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tcs *TracingConfigStatus) DeepCopyInto(out *TracingConfigStatus) {
+	*out = *tcs
+	if tcs.AppliedAt != nil {
+		in, out := &tcs.AppliedAt, &out.AppliedAt
+		*out = (*in).DeepCopy()
+	}
+	if tcs.Conditions != nil {
+		in, out := &tcs.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if tcs.NextScheduleTransition != nil {
+		in, out := &tcs.NextScheduleTransition, &out.NextScheduleTransition
+		*out = (*in).DeepCopy()
+	}
+	if tcs.Workloads != nil {
+		in, out := &tcs.Workloads, &out.Workloads
+		*out = make([]WorkloadStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if tcs.TargetNamespaces != nil {
+		in, out := &tcs.TargetNamespaces, &out.TargetNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.ConfigMaps != nil {
+		in, out := &tcs.ConfigMaps, &out.ConfigMaps
+		*out = make([]ConfigMapReference, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.FailedWorkloads != nil {
+		in, out := &tcs.FailedWorkloads, &out.FailedWorkloads
+		*out = make([]WorkloadFailure, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.SkippedWorkloads != nil {
+		in, out := &tcs.SkippedWorkloads, &out.SkippedWorkloads
+		*out = make([]SkippedWorkload, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.Rollout != nil {
+		in, out := &tcs.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if tcs.Drift != nil {
+		in, out := &tcs.Drift, &out.Drift
+		*out = make([]DriftReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if tcs.ResolvedSpec != nil {
+		in, out := &tcs.ResolvedSpec, &out.ResolvedSpec
+		*out = new(TracingConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if tcs.Defaults != nil {
+		in, out := &tcs.Defaults, &out.Defaults
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.DefaultedFields != nil {
+		in, out := &tcs.DefaultedFields, &out.DefaultedFields
+		*out = make([]DefaultedField, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.PolicyViolations != nil {
+		in, out := &tcs.PolicyViolations, &out.PolicyViolations
+		*out = make([]PolicyViolation, len(*in))
+		copy(*out, *in)
+	}
+	if tcs.MaxSamplingRate != nil {
+		in, out := &tcs.MaxSamplingRate, &out.MaxSamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+	if tcs.Budget != nil {
+		in, out := &tcs.Budget, &out.Budget
+		*out = new(BudgetStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (bs *BudgetStatus) DeepCopyInto(out *BudgetStatus) {
+	*out = *bs
+	if bs.ObservedSpansPerSecond != nil {
+		in, out := &bs.ObservedSpansPerSecond, &out.ObservedSpansPerSecond
+		*out = new(float64)
+		**out = **in
+	}
+	if bs.LastAdjusted != nil {
+		in, out := &bs.LastAdjusted, &out.LastAdjusted
+		*out = (*in).DeepCopy()
+	}
+	if bs.Decisions != nil {
+		in, out := &bs.Decisions, &out.Decisions
+		*out = make([]BudgetDecision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (bd *BudgetDecision) DeepCopyInto(out *BudgetDecision) {
+	*out = *bd
+	bd.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (dr *DriftReport) DeepCopyInto(out *DriftReport) {
+	*out = *dr
+	if dr.Diff != nil {
+		in, out := &dr.Diff, &out.Diff
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (rs *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *rs
+	if rs.LastBatchAt != nil {
+		in, out := &rs.LastBatchAt, &out.LastBatchAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (ws *WorkloadStatus) DeepCopyInto(out *WorkloadStatus) {
+	*out = *ws
+	if ws.InjectedContainers != nil {
+		in, out := &ws.InjectedContainers, &out.InjectedContainers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if ws.ObservedConfigHashes != nil {
+		in, out := &ws.ObservedConfigHashes, &out.ObservedConfigHashes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if ws.EnvConflicts != nil {
+		in, out := &ws.EnvConflicts, &out.EnvConflicts
+		*out = make([]EnvConflict, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (tcl *TracingConfigList) DeepCopyObject() runtime.Object {
+	if tcl == nil {
+		return nil
+	}
+	out := new(TracingConfigList)
+	tcl.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tcl *TracingConfigList) DeepCopyInto(out *TracingConfigList) {
+	*out = *tcl
+	out.TypeMeta = tcl.TypeMeta
+	tcl.ListMeta.DeepCopyInto(&out.ListMeta)
+	if tcl.Items != nil {
+		in, out := &tcl.Items, &out.Items
+		*out = make([]TracingConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// RespectsEnv reports whether OTEL_* env vars the containers set themselves are left in place
+func (s *TracingConfigSpec) RespectsEnv() bool {
+	return s.EnvConflictPolicy != EnvConflictOverride && s.EnvConflictPolicy != EnvConflictFail
+}
+
+// HistoryLimit returns how many revisions of the spec are kept
+func (s *TracingConfigSpec) HistoryLimit() int {
+	if s.RevisionHistoryLimit == nil {
+		return 10
+	}
+	return int(*s.RevisionHistoryLimit)
+}
+
+// EnforcesDrift reports whether manual changes to injected resources are overwritten
+func (s *TracingConfigSpec) EnforcesDrift() bool {
+	return s.DriftPolicy != DriftPolicyReportOnly
+}
+
+// BatchSizeFor returns how many of total workloads one rollout step moves, at least one
+func (s *RolloutStrategy) BatchSizeFor(total int) int {
+	size := 1
+	switch {
+	case s.BatchSize > 0:
+		size = int(s.BatchSize)
+	case s.BatchPercentage > 0:
+		size = int(math.Ceil(float64(total) * float64(s.BatchPercentage) / 100))
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// CASecretKey returns the key of the CA bundle within CASecretName
+func (t *TracingTLSConfig) CASecretKey() string {
+	if t.CAKey == "" {
+		return defaultCAKey
+	}
+	return t.CAKey
+}
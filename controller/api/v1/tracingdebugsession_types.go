@@ -0,0 +1,129 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// AnnotationDebugSession marks pod templates that currently carry a debug session override
+	AnnotationDebugSession = "tracing.kubevishwa.io/debug-session"
+	// AnnotationRequestedBy records the user that created a TracingDebugSession. It is set from
+	// the admission request by the controller's mutating webhook and cannot be changed afterwards.
+	AnnotationRequestedBy = "tracing.kubevishwa.io/requested-by"
+
+	DebugSessionActive  = "Active"
+	DebugSessionExpired = "Expired"
+	DebugSessionFailed  = "Failed"
+)
+
+// TracingDebugSession temporarily overrides sampling for a subset of pods
+type TracingDebugSession struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TracingDebugSessionSpec   `json:"spec,omitempty"`
+	Status            TracingDebugSessionStatus `json:"status,omitempty"`
+}
+
+type TracingDebugSessionSpec struct {
+	// Selector matches the pod template labels of workloads already injected by a TracingConfig
+	Selector     *metav1.LabelSelector `json:"selector"`
+	SamplingRate *float64              `json:"samplingRate,omitempty"`
+	Duration     string                `json:"duration"`
+	// RequestedBy is who the session is declared for; the user that created it is recorded in status
+	RequestedBy string `json:"requestedBy,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+type TracingDebugSessionStatus struct {
+	Phase       string       `json:"phase,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	RequestedBy string       `json:"requestedBy,omitempty"`
+	RequestedAt *metav1.Time `json:"requestedAt,omitempty"`
+	ExpiresAt   *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+type TracingDebugSessionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TracingDebugSession `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (ds *TracingDebugSession) DeepCopyObject() runtime.Object {
+	if ds == nil {
+		return nil
+	}
+	out := new(TracingDebugSession)
+	ds.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (ds *TracingDebugSession) DeepCopyInto(out *TracingDebugSession) {
+	*out = *ds
+	out.TypeMeta = ds.TypeMeta
+	ds.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	ds.Spec.DeepCopyInto(&out.Spec)
+	ds.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (dss *TracingDebugSessionSpec) DeepCopyInto(out *TracingDebugSessionSpec) {
+	*out = *dss
+	if dss.Selector != nil {
+		in, out := &dss.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if dss.SamplingRate != nil {
+		in, out := &dss.SamplingRate, &out.SamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (dss *TracingDebugSessionStatus) DeepCopyInto(out *TracingDebugSessionStatus) {
+	*out = *dss
+	if dss.RequestedAt != nil {
+		in, out := &dss.RequestedAt, &out.RequestedAt
+		*out = (*in).DeepCopy()
+	}
+	if dss.ExpiresAt != nil {
+		in, out := &dss.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (dsl *TracingDebugSessionList) DeepCopyObject() runtime.Object {
+	if dsl == nil {
+		return nil
+	}
+	out := new(TracingDebugSessionList)
+	dsl.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (dsl *TracingDebugSessionList) DeepCopyInto(out *TracingDebugSessionList) {
+	*out = *dsl
+	out.TypeMeta = dsl.TypeMeta
+	dsl.ListMeta.DeepCopyInto(&out.ListMeta)
+	if dsl.Items != nil {
+		in, out := &dsl.Items, &out.Items
+		*out = make([]TracingDebugSession, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// EffectiveSamplingRate is the sampling ratio the session applies, 100% by default
+func (ds *TracingDebugSession) EffectiveSamplingRate() float64 {
+	if ds.Spec.SamplingRate == nil {
+		return 1.0
+	}
+	return *ds.Spec.SamplingRate
+}
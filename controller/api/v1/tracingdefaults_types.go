@@ -0,0 +1,106 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TracingDefaults supplies the export settings of every TracingConfig in its namespace that
+// leaves them unset. Several TracingDefaults are applied in name order, later ones winning.
+type TracingDefaults struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TracingDefaultsSpec `json:"spec,omitempty"`
+}
+
+// TracingDefaultsSpec holds the TracingConfigSpec fields that can be defaulted. Whether and how
+// much to trace, the service name and the targets are always set per TracingConfig.
+type TracingDefaultsSpec struct {
+	Endpoint      string            `json:"endpoint,omitempty"`
+	Protocol      string            `json:"protocol,omitempty"`
+	Compression   string            `json:"compression,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	ExportTimeout string            `json:"exportTimeout,omitempty"`
+	BatchTimeout  string            `json:"batchTimeout,omitempty"`
+	MaxBatchSize  int               `json:"maxBatchSize,omitempty"`
+	TLS           *TracingTLSConfig `json:"tls,omitempty"`
+}
+
+// DefaultedField names a field a TracingConfig left unset and the TracingDefaults that set it
+type DefaultedField struct {
+	Field    string `json:"field"`
+	Defaults string `json:"defaults"`
+}
+
+type TracingDefaultsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TracingDefaults `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (td *TracingDefaults) DeepCopyObject() runtime.Object {
+	if td == nil {
+		return nil
+	}
+	out := new(TracingDefaults)
+	td.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (td *TracingDefaults) DeepCopyInto(out *TracingDefaults) {
+	*out = *td
+	out.TypeMeta = td.TypeMeta
+	td.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	td.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tds *TracingDefaultsSpec) DeepCopyInto(out *TracingDefaultsSpec) {
+	*out = *tds
+	if tds.Headers != nil {
+		in, out := &tds.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tds.Attributes != nil {
+		in, out := &tds.Attributes, &out.Attributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if tds.TLS != nil {
+		in, out := &tds.TLS, &out.TLS
+		*out = new(TracingTLSConfig)
+		**out = **in
+	}
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (tdl *TracingDefaultsList) DeepCopyObject() runtime.Object {
+	if tdl == nil {
+		return nil
+	}
+	out := new(TracingDefaultsList)
+	tdl.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tdl *TracingDefaultsList) DeepCopyInto(out *TracingDefaultsList) {
+	*out = *tdl
+	out.TypeMeta = tdl.TypeMeta
+	tdl.ListMeta.DeepCopyInto(&out.ListMeta)
+	if tdl.Items != nil {
+		in, out := &tdl.Items, &out.Items
+		*out = make([]TracingDefaults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Actions a TracingPolicy takes on a sampling rate above its cap
+const (
+	PolicyActionClamp  = "Clamp"
+	PolicyActionReject = "Reject"
+)
+
+// Outcomes of a policy violation
+const (
+	PolicyViolationClamped  = "Clamped"
+	PolicyViolationRejected = "Rejected"
+)
+
+// TracingPolicy puts cluster-wide guardrails on the TracingConfigs that target the namespaces it
+// selects. It is checked by the validating webhook when TracingConfigs are written and by the
+// controller on every reconcile.
+type TracingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TracingPolicySpec `json:"spec,omitempty"`
+}
+
+// TracingPolicySpec defines the limits of a TracingPolicy
+type TracingPolicySpec struct {
+	// NamespaceSelector picks the namespaces the policy applies to; all of them when unset
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// MaxSamplingRate caps the sampling rate, including the one of every schedule window
+	MaxSamplingRate *float64 `json:"maxSamplingRate,omitempty"`
+
+	// AllowedEndpoints lists the endpoints traces may be exported to, as host or host:port
+	// patterns where * matches any sequence of characters; any endpoint when empty
+	AllowedEndpoints []string `json:"allowedEndpoints,omitempty"`
+
+	// Action is Clamp (default) to lower a sampling rate above the cap to it, or Reject to refuse
+	// the TracingConfig. An endpoint outside the allow-list is always rejected.
+	Action string `json:"action,omitempty"`
+}
+
+// PolicyViolation describes a TracingConfig setting outside the limits of a TracingPolicy
+type PolicyViolation struct {
+	Policy  string `json:"policy"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	// Outcome is Clamped when the setting was lowered to the limit, Rejected when the
+	// TracingConfig was not rendered
+	Outcome string `json:"outcome"`
+}
+
+type TracingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TracingPolicy `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (tp *TracingPolicy) DeepCopyObject() runtime.Object {
+	if tp == nil {
+		return nil
+	}
+	out := new(TracingPolicy)
+	tp.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tp *TracingPolicy) DeepCopyInto(out *TracingPolicy) {
+	*out = *tp
+	out.TypeMeta = tp.TypeMeta
+	tp.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	tp.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tps *TracingPolicySpec) DeepCopyInto(out *TracingPolicySpec) {
+	*out = *tps
+	if tps.NamespaceSelector != nil {
+		in, out := &tps.NamespaceSelector, &out.NamespaceSelector
+		*out = (*in).DeepCopy()
+	}
+	if tps.MaxSamplingRate != nil {
+		in, out := &tps.MaxSamplingRate, &out.MaxSamplingRate
+		*out = new(float64)
+		**out = **in
+	}
+	if tps.AllowedEndpoints != nil {
+		in, out := &tps.AllowedEndpoints, &out.AllowedEndpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopyObject implements runtime.Object interface
+func (tpl *TracingPolicyList) DeepCopyObject() runtime.Object {
+	if tpl == nil {
+		return nil
+	}
+	out := new(TracingPolicyList)
+	tpl.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies all properties of this object into another object of the same type
+func (tpl *TracingPolicyList) DeepCopyInto(out *TracingPolicyList) {
+	*out = *tpl
+	out.TypeMeta = tpl.TypeMeta
+	tpl.ListMeta.DeepCopyInto(&out.ListMeta)
+	if tpl.Items != nil {
+		in, out := &tpl.Items, &out.Items
+		*out = make([]TracingPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
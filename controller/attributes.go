@@ -0,0 +1,57 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// annotationResourceAttributes marks pod templates whose containers carry the resource attribute env
+const annotationResourceAttributes = "tracing.kubevishwa.io/resource-attributes"
+
+// ensureResourceAttributes sets the downward API env vars and OTEL_RESOURCE_ATTRIBUTES rendered
+// for the workload on the containers matched by targets, or removes them when spec is nil. It
+// reports whether the template was modified.
+func ensureResourceAttributes(template *corev1.PodTemplateSpec, spec *tracingv1.TracingConfigSpec, workload render.WorkloadReference, targets func(container string) bool) bool {
+	_, injected := template.Annotations[annotationResourceAttributes]
+	if spec == nil && !injected {
+		return false
+	}
+
+	updated := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		targeted := spec != nil && targets(container.Name)
+		if !targeted && !injected {
+			continue
+		}
+		env := make([]corev1.EnvVar, 0, len(container.Env))
+		for _, envVar := range container.Env {
+			if !render.IsResourceAttributeEnv(envVar.Name) {
+				env = append(env, envVar)
+			}
+		}
+		if targeted {
+			env = append(env, render.ResourceAttributeEnv(spec, workload, container.Name)...)
+		}
+		if !equality.Semantic.DeepEqual(env, container.Env) {
+			container.Env = env
+			updated = true
+		}
+	}
+
+	switch {
+	case spec == nil:
+		delete(template.Annotations, annotationResourceAttributes)
+		updated = true
+	case !injected:
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[annotationResourceAttributes] = "true"
+		updated = true
+	}
+	return updated
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	reasonSamplingAdjusted = "SamplingAdjusted"
+
+	// Defaults for the counter of the collector's spanmetrics connector
+	defaultSpanMetric       = "traces_spanmetrics_calls_total"
+	defaultSpanServiceLabel = "service_name"
+
+	// minRateWindow is the least time between the two scrapes a throughput is computed from
+	minRateWindow = 30 * time.Second
+)
+
+// SpanThroughputSource reports how many spans per second the collector receives from the
+// services. ok is false while there is not enough data for a rate yet.
+type SpanThroughputSource interface {
+	SpanRate(ctx context.Context, services []string) (rate float64, ok bool, err error)
+}
+
+// collectorMetrics computes span throughput from a per-service span counter on the collector's
+// Prometheus metrics endpoint, as the increase between two scrapes
+type collectorMetrics struct {
+	URL          string
+	Metric       string
+	ServiceLabel string
+	Client       *http.Client
+
+	mu      sync.Mutex
+	samples map[string]counterSample
+}
+
+// counterSample is the value a service's span counter had at a scrape
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+func (c *collectorMetrics) SpanRate(ctx context.Context, services []string) (float64, bool, error) {
+	counters, err := c.scrape(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == nil {
+		c.samples = map[string]counterSample{}
+	}
+	now := time.Now()
+
+	// Too short a window gives a noisy rate, keep the samples for the next scrape
+	for _, service := range services {
+		if previous, ok := c.samples[service]; ok && now.Sub(previous.at) < minRateWindow {
+			return 0, false, nil
+		}
+	}
+
+	total, complete := 0.0, len(services) > 0
+	for _, service := range services {
+		value := counters[service]
+		previous, ok := c.samples[service]
+		c.samples[service] = counterSample{value: value, at: now}
+		// A counter that went down was reset by a collector restart
+		if !ok || value < previous.value {
+			complete = false
+			continue
+		}
+		total += (value - previous.value) / now.Sub(previous.at).Seconds()
+	}
+	return total, complete, nil
+}
+
+// scrape reads the span counter of every service from the metrics endpoint
+func (c *collectorMetrics) scrape(ctx context.Context) (map[string]float64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape %s: %w", c.URL, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape %s: %s", c.URL, response.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics from %s: %w", c.URL, err)
+	}
+
+	// Until a service has sent spans there is no series for it
+	counters := map[string]float64{}
+	if family, ok := families[c.Metric]; ok {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == c.ServiceLabel {
+					counters[label.GetValue()] += metric.GetCounter().GetValue() + metric.GetUntyped().GetValue()
+				}
+			}
+		}
+	}
+	return counters, nil
+}
+
+// reportedServices returns the service names the TracingConfig's workloads report
+func reportedServices(tracingConfig *tracingv1.TracingConfig) []string {
+	// Templated service names are only known per workload
+	seen := map[string]bool{}
+	var services []string
+	for _, workload := range tracingConfig.Status.Workloads {
+		if workload.ServiceName != "" && !seen[workload.ServiceName] {
+			seen[workload.ServiceName] = true
+			services = append(services, workload.ServiceName)
+		}
+	}
+	if len(services) == 0 && !render.IsServiceNameTemplate(tracingConfig.Spec.ServiceName) {
+		services = append(services, tracingConfig.Spec.ServiceName)
+	}
+	return services
+}
+
+// adjustBudget picks the sampling rate for spec.budget from the span throughput the collector
+// observed, at most once per interval, and reports the decision in status. The rate is only
+// adjusted while every pod runs the previous one and no schedule window sets it.
+func (r *TracingConfigReconciler) adjustBudget(ctx context.Context, tracingConfig *tracingv1.TracingConfig, active render.ActiveSchedule, now time.Time) (err error) {
+	budget := tracingConfig.Spec.Budget
+	if budget == nil {
+		tracingConfig.Status.Budget = nil
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "AdjustBudget", attribute.Float64("budget.spans_per_second", budget.SpansPerSecond))
+	defer func() { endSpan(span, err) }()
+
+	interval, err := render.BudgetInterval(budget)
+	if err != nil {
+		return terminal("InvalidBudget", err)
+	}
+	// Never aim above the cap of a TracingPolicy, the rendered rate would not follow
+	min, max := render.BudgetBounds(budget)
+	if limit := tracingConfig.Status.MaxSamplingRate; limit != nil && *limit < max {
+		max = math.Max(min, *limit)
+	}
+
+	status := tracingConfig.Status.Budget
+	if status == nil {
+		status = &tracingv1.BudgetStatus{SamplingRate: tracingConfig.Spec.SamplingRate}
+		tracingConfig.Status.Budget = status
+	}
+	status.SamplingRate = math.Max(min, math.Min(max, status.SamplingRate))
+	span.SetAttributes(attribute.Float64("budget.sampling_rate", status.SamplingRate))
+
+	if r.Throughput == nil {
+		status.Message = "No collector metrics endpoint is configured, the sampling rate is not adjusted"
+		return nil
+	}
+	// Scrape on every reconcile, so that the throughput is measured over the latest requeue interval
+	observed, ok, err := r.Throughput.SpanRate(ctx, reportedServices(tracingConfig))
+	switch {
+	case err != nil:
+		log.Printf("Failed to read span throughput for TracingConfig %s/%s: %v", tracingConfig.Namespace, tracingConfig.Name, err)
+		status.Message = fmt.Sprintf("Cannot read span throughput: %v", err)
+		return nil
+	case !ok:
+		if status.ObservedSpansPerSecond == nil {
+			status.Message = "Measuring span throughput"
+		}
+		return nil
+	}
+	status.ObservedSpansPerSecond = &observed
+	span.SetAttributes(attribute.Float64("budget.observed_spans_per_second", observed))
+
+	switch {
+	case active.Window != nil && active.Window.SamplingRate != nil:
+		status.Message = fmt.Sprintf("Schedule window %s sets the sampling rate", active.Window.Name)
+		return nil
+	case tracingConfig.Status.StalePods > 0:
+		status.Message = "Waiting for every pod to run the current sampling rate"
+		return nil
+	case status.LastAdjusted != nil && now.Sub(status.LastAdjusted.Time) < interval:
+		return nil
+	}
+
+	rate, changed := render.BudgetSamplingRate(budget, status.SamplingRate, observed, min, max)
+	if !changed {
+		status.Message = fmt.Sprintf("Observed %.1f spans/s against a budget of %g", observed, budget.SpansPerSecond)
+		return nil
+	}
+
+	decision := tracingv1.BudgetDecision{
+		Time:                   metav1.NewTime(now),
+		ObservedSpansPerSecond: observed,
+		FromSamplingRate:       status.SamplingRate,
+		ToSamplingRate:         rate,
+	}
+	status.Decisions = append(status.Decisions, decision)
+	if len(status.Decisions) > render.MaxBudgetDecisions {
+		status.Decisions = status.Decisions[len(status.Decisions)-render.MaxBudgetDecisions:]
+	}
+	status.SamplingRate = rate
+	status.LastAdjusted = &decision.Time
+	status.Message = fmt.Sprintf("Adjusted the sampling rate from %s to %s, observed %.1f spans/s against a budget of %g",
+		render.FormatSamplingRate(decision.FromSamplingRate), render.FormatSamplingRate(rate), observed, budget.SpansPerSecond)
+	log.Printf("TracingConfig %s/%s: %s", tracingConfig.Namespace, tracingConfig.Name, status.Message)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(tracingConfig, corev1.EventTypeNormal, reasonSamplingAdjusted, "%s", status.Message)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// metricsServer serves a span counter per service in the Prometheus text format
+type metricsServer struct {
+	mu       sync.Mutex
+	counters map[string]float64
+}
+
+func (s *metricsServer) set(service string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[service] = value
+}
+
+func (s *metricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(w, "# TYPE %s counter\n", defaultSpanMetric)
+	for service, value := range s.counters {
+		// The connector reports one series per span name and kind, summed per service
+		fmt.Fprintf(w, "%s{%s=%q,span_kind=\"SPAN_KIND_SERVER\"} %g\n", defaultSpanMetric, defaultSpanServiceLabel, service, value/2)
+		fmt.Fprintf(w, "%s{%s=%q,span_kind=\"SPAN_KIND_CLIENT\"} %g\n", defaultSpanMetric, defaultSpanServiceLabel, service, value/2)
+	}
+}
+
+// backdate moves every sample back, as if it had been scraped that much earlier
+func backdate(c *collectorMetrics, by time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for service, sample := range c.samples {
+		sample.at = sample.at.Add(-by)
+		c.samples[service] = sample
+	}
+}
+
+func TestCollectorMetricsSpanRate(t *testing.T) {
+	metrics := &metricsServer{counters: map[string]float64{"checkout": 100, "payments": 50}}
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	source := &collectorMetrics{URL: server.URL, Metric: defaultSpanMetric, ServiceLabel: defaultSpanServiceLabel, Client: server.Client()}
+	services := []string{"checkout", "payments"}
+	ctx := context.Background()
+
+	// The first scrape only records the counters
+	if _, ok, err := source.SpanRate(ctx, services); err != nil || ok {
+		t.Fatalf("first SpanRate() = %v, %v, want no rate yet", ok, err)
+	}
+
+	// A scrape within the window keeps the first samples
+	metrics.set("checkout", 130)
+	if _, ok, err := source.SpanRate(ctx, services); err != nil || ok {
+		t.Fatalf("SpanRate() within the window = %v, %v, want no rate", ok, err)
+	}
+
+	backdate(source, time.Minute)
+	metrics.set("checkout", 160)
+	metrics.set("payments", 80)
+	rate, ok, err := source.SpanRate(ctx, services)
+	if err != nil || !ok {
+		t.Fatalf("SpanRate() after a minute = %v, %v, want a rate", ok, err)
+	}
+	if want := (60.0 + 30.0) / 60; math.Abs(rate-want) > 0.01 {
+		t.Errorf("SpanRate() = %.3f, want %.3f", rate, want)
+	}
+}
+
+func TestCollectorMetricsSpanRateCounterReset(t *testing.T) {
+	metrics := &metricsServer{counters: map[string]float64{"checkout": 1000}}
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	source := &collectorMetrics{URL: server.URL, Metric: defaultSpanMetric, ServiceLabel: defaultSpanServiceLabel, Client: server.Client()}
+	services := []string{"checkout"}
+	ctx := context.Background()
+
+	if _, _, err := source.SpanRate(ctx, services); err != nil {
+		t.Fatal(err)
+	}
+
+	// A collector restart resets the counter, which gives no rate but a new baseline
+	backdate(source, time.Minute)
+	metrics.set("checkout", 20)
+	if _, ok, err := source.SpanRate(ctx, services); err != nil || ok {
+		t.Fatalf("SpanRate() after a reset = %v, %v, want no rate", ok, err)
+	}
+
+	backdate(source, time.Minute)
+	metrics.set("checkout", 140)
+	rate, ok, err := source.SpanRate(ctx, services)
+	if err != nil || !ok {
+		t.Fatalf("SpanRate() after the new baseline = %v, %v, want a rate", ok, err)
+	}
+	if want := 120.0 / 60; math.Abs(rate-want) > 0.01 {
+		t.Errorf("SpanRate() = %.3f, want %.3f", rate, want)
+	}
+}
+
+func TestCollectorMetricsSpanRateScrapeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	source := &collectorMetrics{URL: server.URL, Metric: defaultSpanMetric, ServiceLabel: defaultSpanServiceLabel, Client: server.Client()}
+
+	if _, ok, err := source.SpanRate(context.Background(), []string{"checkout"}); err == nil || ok {
+		t.Fatalf("SpanRate() = %v, %v, want a scrape error", ok, err)
+	}
+}
+
+// fakeThroughput reports a fixed span rate
+type fakeThroughput struct {
+	rate float64
+	ok   bool
+}
+
+func (f *fakeThroughput) SpanRate(context.Context, []string) (float64, bool, error) {
+	return f.rate, f.ok, nil
+}
+
+func budgetTracingConfig(samplingRate float64, budget tracingv1.SpanBudget) *tracingv1.TracingConfig {
+	return &tracingv1.TracingConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+		Spec: tracingv1.TracingConfigSpec{
+			ServiceName:  "checkout",
+			SamplingRate: samplingRate,
+			Budget:       &budget,
+		},
+	}
+}
+
+func TestAdjustBudget(t *testing.T) {
+	now := time.Now()
+	limit := 0.2
+
+	tests := []struct {
+		name         string
+		samplingRate float64
+		budget       tracingv1.SpanBudget
+		policyCap    *float64
+		observed     float64
+		lastAdjusted *time.Time
+		want         float64
+		decided      bool
+	}{
+		{name: "over budget", samplingRate: 0.5, budget: tracingv1.SpanBudget{SpansPerSecond: 100}, observed: 200, want: 0.25, decided: true},
+		{name: "under budget", samplingRate: 0.1, budget: tracingv1.SpanBudget{SpansPerSecond: 100}, observed: 50, want: 0.2, decided: true},
+		{name: "within tolerance", samplingRate: 0.5, budget: tracingv1.SpanBudget{SpansPerSecond: 100}, observed: 105, want: 0.5},
+		{name: "capped by policy", samplingRate: 0.1, budget: tracingv1.SpanBudget{SpansPerSecond: 100}, policyCap: &limit, observed: 25, want: 0.2, decided: true},
+		{name: "interval not elapsed", samplingRate: 0.5, budget: tracingv1.SpanBudget{SpansPerSecond: 100, Interval: "1h"}, observed: 200, lastAdjusted: &now, want: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracingConfig := budgetTracingConfig(tt.samplingRate, tt.budget)
+			tracingConfig.Status.MaxSamplingRate = tt.policyCap
+			if tt.lastAdjusted != nil {
+				lastAdjusted := metav1.NewTime(tt.lastAdjusted.Add(-time.Minute))
+				tracingConfig.Status.Budget = &tracingv1.BudgetStatus{SamplingRate: tt.samplingRate, LastAdjusted: &lastAdjusted}
+			}
+			r := &TracingConfigReconciler{Throughput: &fakeThroughput{rate: tt.observed, ok: true}}
+
+			if err := r.adjustBudget(context.Background(), tracingConfig, render.ActiveSchedule{}, now); err != nil {
+				t.Fatal(err)
+			}
+			status := tracingConfig.Status.Budget
+			if status.SamplingRate != tt.want {
+				t.Errorf("sampling rate = %g, want %g", status.SamplingRate, tt.want)
+			}
+			if decided := len(status.Decisions) > 0; decided != tt.decided {
+				t.Errorf("decisions = %v, want a decision: %v", status.Decisions, tt.decided)
+			}
+			if status.ObservedSpansPerSecond == nil || *status.ObservedSpansPerSecond != tt.observed {
+				t.Errorf("observed spans per second = %v, want %g", status.ObservedSpansPerSecond, tt.observed)
+			}
+		})
+	}
+}
+
+func TestAdjustBudgetWaitsForThroughput(t *testing.T) {
+	tracingConfig := budgetTracingConfig(0.5, tracingv1.SpanBudget{SpansPerSecond: 100})
+	r := &TracingConfigReconciler{Throughput: &fakeThroughput{}}
+
+	if err := r.adjustBudget(context.Background(), tracingConfig, render.ActiveSchedule{}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if status := tracingConfig.Status.Budget; status.SamplingRate != 0.5 || status.ObservedSpansPerSecond != nil {
+		t.Errorf("budget status = %+v, want the spec's rate and no throughput yet", status)
+	}
+}
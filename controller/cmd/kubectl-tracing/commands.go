@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	defaultEndpoint = "otel-collector.observability.svc.cluster.local:4317"
+
+	// labelManagedBy marks the TracingConfigs created by the plugin
+	labelManagedBy = "app.kubernetes.io/managed-by"
+)
+
+// tracingConfigNameFor is the name of the TracingConfig the plugin manages for a deployment
+func tracingConfigNameFor(deployment string) string {
+	return deployment + "-tracing"
+}
+
+func (c *cli) getDeployment(ctx context.Context, name string) (*appsv1.Deployment, error) {
+	var deployment appsv1.Deployment
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: c.namespace, Name: name}, &deployment); err != nil {
+		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", c.namespace, name, err)
+	}
+	return &deployment, nil
+}
+
+// defaultEndpoint returns the endpoint the namespace's TracingDefaults provide, if any
+func (c *cli) defaultEndpoint(ctx context.Context) (string, error) {
+	var defaults tracingv1.TracingDefaultsList
+	if err := c.client.List(ctx, &defaults, client.InNamespace(c.namespace)); err != nil {
+		return "", fmt.Errorf("failed to list tracing defaults: %w", err)
+	}
+	resolved, _ := render.ApplyDefaults(tracingv1.TracingConfigSpec{}, defaults.Items)
+	return resolved.Endpoint, nil
+}
+
+// enable creates a TracingConfig selecting just this deployment, or re-enables the existing one
+func (c *cli) enable(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("enable", flag.ExitOnError)
+	rate := flags.Float64("rate", 0.1, "Sampling rate between 0 and 1")
+	endpoint := flags.String("endpoint", "", "OTLP endpoint (new TracingConfigs inherit the namespace's TracingDefaults, or use "+defaultEndpoint+")")
+	serviceName := flags.String("service-name", "", "Service name reported in traces (defaults to the deployment name)")
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+	if *rate < 0 || *rate > 1 {
+		return fmt.Errorf("--rate must be between 0 and 1")
+	}
+
+	deployment, err := c.getDeployment(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// Point out other TracingConfigs that already inject this deployment
+	resolution, err := c.resolve(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	for _, candidate := range resolution.Candidates {
+		if candidate.Name != tracingConfigNameFor(name) || candidate.Namespace != c.namespace {
+			fmt.Printf("warning: deployment %s is also selected by TracingConfig %s/%s\n", name, candidate.Namespace, candidate.Name)
+		}
+	}
+
+	var tracingConfig tracingv1.TracingConfig
+	key := types.NamespacedName{Namespace: c.namespace, Name: tracingConfigNameFor(name)}
+	err = c.client.Get(ctx, key, &tracingConfig)
+	if apierrors.IsNotFound(err) {
+		// The controller selects deployments by their own labels, not their pods'; without any,
+		// the selector would match every deployment in the namespace
+		if len(deployment.Labels) == 0 {
+			return fmt.Errorf("deployment %s has no labels to select it by", name)
+		}
+		tracingConfig = tracingv1.TracingConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{labelManagedBy: fieldManager},
+			},
+			Spec: tracingv1.TracingConfigSpec{
+				Enabled:      true,
+				SamplingRate: *rate,
+				ServiceName:  name,
+				Selector:     &metav1.LabelSelector{MatchLabels: deployment.DeepCopy().Labels},
+			},
+		}
+		if *endpoint != "" {
+			tracingConfig.Spec.Endpoint = *endpoint
+		} else if inherited, err := c.defaultEndpoint(ctx); err != nil {
+			return err
+		} else if inherited == "" {
+			tracingConfig.Spec.Endpoint = defaultEndpoint
+		}
+		if *serviceName != "" {
+			tracingConfig.Spec.ServiceName = *serviceName
+		}
+		if err := c.client.Create(ctx, &tracingConfig, client.FieldOwner(fieldManager)); err != nil {
+			return fmt.Errorf("failed to create TracingConfig %s: %w", key, err)
+		}
+		fmt.Printf("tracingconfig/%s created, sampling %s of %s\n", key.Name, render.FormatSamplingRate(*rate), name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get TracingConfig %s: %w", key, err)
+	}
+
+	tracingConfig.Spec.Enabled = true
+	tracingConfig.Spec.SamplingRate = *rate
+	if *endpoint != "" {
+		tracingConfig.Spec.Endpoint = *endpoint
+	}
+	if *serviceName != "" {
+		tracingConfig.Spec.ServiceName = *serviceName
+	}
+	if err := c.client.Update(ctx, &tracingConfig, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to update TracingConfig %s: %w", key, err)
+	}
+	fmt.Printf("tracingconfig/%s enabled, sampling %s of %s\n", key.Name, render.FormatSamplingRate(*rate), name)
+	return nil
+}
+
+// disable turns off the TracingConfig created by enable. TracingConfigs written by hand
+// usually cover more than one deployment, so they are only pointed out.
+func (c *cli) disable(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("disable", flag.ExitOnError)
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+
+	var tracingConfig tracingv1.TracingConfig
+	key := types.NamespacedName{Namespace: c.namespace, Name: tracingConfigNameFor(name)}
+	if err := c.client.Get(ctx, key, &tracingConfig); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get TracingConfig %s: %w", key, err)
+		}
+
+		deployment, err := c.getDeployment(ctx, name)
+		if err != nil {
+			return err
+		}
+		resolution, err := c.resolve(ctx, deployment)
+		if err != nil {
+			return err
+		}
+		if len(resolution.Candidates) == 0 {
+			fmt.Printf("deployment %s is not traced\n", name)
+			return nil
+		}
+		for _, candidate := range resolution.Candidates {
+			fmt.Printf("deployment %s is traced by TracingConfig %s/%s, edit it to disable tracing\n", name, candidate.Namespace, candidate.Name)
+		}
+		return fmt.Errorf("no TracingConfig was created for %s by kubectl tracing enable", name)
+	}
+
+	tracingConfig.Spec.Enabled = false
+	if err := c.client.Update(ctx, &tracingConfig, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to update TracingConfig %s: %w", key, err)
+	}
+	fmt.Printf("tracingconfig/%s disabled\n", key.Name)
+	return nil
+}
+
+// debug creates a TracingDebugSession for the pods of the deployment
+func (c *cli) debug(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	duration := flags.Duration("for", 30*time.Minute, "How long the session lasts")
+	rate := flags.Float64("rate", 1.0, "Sampling rate during the session")
+	reason := flags.String("reason", "", "Why the session is needed")
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+	if *duration <= 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+	if *rate < 0 || *rate > 1 {
+		return fmt.Errorf("--rate must be between 0 and 1")
+	}
+
+	deployment, err := c.getDeployment(ctx, name)
+	if err != nil {
+		return err
+	}
+	if deployment.Spec.Template.Annotations[tracingv1.AnnotationConfigHash] == "" {
+		fmt.Printf("warning: deployment %s is not injected by any TracingConfig yet, the session has no effect until it is\n", name)
+	}
+
+	session := tracingv1.TracingDebugSession{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + "-debug-",
+			Namespace:    c.namespace,
+		},
+		Spec: tracingv1.TracingDebugSessionSpec{
+			Selector:     &metav1.LabelSelector{MatchLabels: deployment.Spec.Template.Labels},
+			SamplingRate: rate,
+			Duration:     duration.String(),
+			RequestedBy:  c.user,
+			Reason:       *reason,
+		},
+	}
+	if err := c.client.Create(ctx, &session, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to create debug session: %w", err)
+	}
+	fmt.Printf("tracingdebugsession/%s created, sampling %s of %s for %s\n", session.Name, render.FormatSamplingRate(*rate), name, duration)
+	return nil
+}
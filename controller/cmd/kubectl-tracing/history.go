@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// tracingConfigOf returns the TracingConfig whose history the deployment's commands work on:
+// the one that injects it, or the only one selecting it
+func (c *cli) tracingConfigOf(ctx context.Context, name string) (*tracingv1.TracingConfig, error) {
+	deployment, err := c.getDeployment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resolution, err := c.resolve(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resolution.Winner != nil:
+		return resolution.Winner, nil
+	case len(resolution.Candidates) == 1:
+		return resolution.Candidates[0], nil
+	case len(resolution.Candidates) == 0:
+		return nil, fmt.Errorf("deployment %s is not selected by any TracingConfig", name)
+	}
+	return nil, fmt.Errorf("deployment %s is selected by %s and none of them injects it yet", name, candidateNames(resolution.Candidates, nil))
+}
+
+func (c *cli) revisions(ctx context.Context, tracingConfig *tracingv1.TracingConfig) ([]*appsv1.ControllerRevision, error) {
+	var revisions appsv1.ControllerRevisionList
+	if err := c.client.List(ctx, &revisions, client.InNamespace(tracingConfig.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	return render.OwnedRevisions(tracingConfig, revisions.Items), nil
+}
+
+// history lists the recorded revisions of the deployment's TracingConfig, or prints one of them
+func (c *cli) history(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	number := flags.Int64("revision", 0, "Print the spec recorded in this revision")
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+
+	tracingConfig, err := c.tracingConfigOf(ctx, name)
+	if err != nil {
+		return err
+	}
+	revisions, err := c.revisions(ctx, tracingConfig)
+	if err != nil {
+		return err
+	}
+
+	if *number != 0 {
+		revision := render.RollbackTarget(revisions, 0, *number)
+		if revision == nil {
+			return fmt.Errorf("TracingConfig %s/%s has no revision %d", tracingConfig.Namespace, tracingConfig.Name, *number)
+		}
+		spec, err := render.SpecFromRevision(revision)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(encoded))
+		return nil
+	}
+
+	fmt.Printf("tracingconfig/%s\n", tracingConfig.Name)
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "REVISION\tRECORDED\tENABLED\tSAMPLING\tENDPOINT\tCURRENT")
+	for _, revision := range revisions {
+		spec, err := render.SpecFromRevision(revision)
+		if err != nil {
+			fmt.Fprintf(out, "%d\t%s\t-\t-\t-\t\n", revision.Revision, revision.CreationTimestamp.Format("2006-01-02 15:04"))
+			continue
+		}
+		current := ""
+		if revision.Revision == tracingConfig.Status.CurrentRevision {
+			current = "*"
+		}
+		endpoint := spec.Endpoint
+		if endpoint == "" {
+			endpoint = "(defaults)"
+		}
+		fmt.Fprintf(out, "%d\t%s\t%t\t%s\t%s\t%s\n", revision.Revision, revision.CreationTimestamp.Format("2006-01-02 15:04"),
+			spec.Enabled, render.FormatSamplingRate(spec.SamplingRate), endpoint, current)
+	}
+	return out.Flush()
+}
+
+// rollback asks the controller to restore a recorded revision of the deployment's TracingConfig
+func (c *cli) rollback(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	number := flags.Int64("to-revision", 0, "Revision to roll back to (defaults to the one before the current revision)")
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+	if *number < 0 {
+		return fmt.Errorf("--to-revision must not be negative")
+	}
+
+	tracingConfig, err := c.tracingConfigOf(ctx, name)
+	if err != nil {
+		return err
+	}
+	revisions, err := c.revisions(ctx, tracingConfig)
+	if err != nil {
+		return err
+	}
+	target := render.RollbackTarget(revisions, tracingConfig.Status.CurrentRevision, *number)
+	if target == nil {
+		return fmt.Errorf("TracingConfig %s/%s has no revision to roll back to", tracingConfig.Namespace, tracingConfig.Name)
+	}
+
+	tracingConfig.Spec.RollbackTo = &tracingv1.RollbackConfig{Revision: target.Revision}
+	if err := c.client.Update(ctx, tracingConfig, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to update TracingConfig %s/%s: %w", tracingConfig.Namespace, tracingConfig.Name, err)
+	}
+	fmt.Printf("tracingconfig/%s rolling back to revision %d\n", tracingConfig.Name, target.Revision)
+	return nil
+}
@@ -0,0 +1,153 @@
+// kubectl-tracing is a kubectl plugin for turning tracing on and off for Deployments without
+// writing TracingConfig YAML. Install it anywhere on the PATH and run `kubectl tracing`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+const usage = `Manage tracing for Deployments through TracingConfigs.
+
+Usage:
+  kubectl tracing [-n namespace] [--kubeconfig path] <command> [flags] [deployment]
+
+Commands:
+  enable <deployment>   Create or enable the TracingConfig of a deployment (--rate, --endpoint, --service-name)
+  disable <deployment>  Disable the TracingConfig created by "enable"
+  status [deployment]   Show the effective tracing configuration per deployment and which TracingConfig wins
+  debug <deployment>    Start a TracingDebugSession for the deployment's pods (--for, --rate, --reason)
+  explain <deployment>  Show every OTEL_* variable the deployment gets and where it comes from
+  history <deployment>  List the recorded revisions of the deployment's TracingConfig (--revision to print one)
+  rollback <deployment> Roll the deployment's TracingConfig back to the previous revision (--to-revision)
+`
+
+// fieldManager identifies the plugin in managedFields and as the creator of its TracingConfigs
+const fieldManager = "kubectl-tracing"
+
+// cli carries what every command needs
+type cli struct {
+	client    client.Client
+	namespace string
+	user      string
+}
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-tracing", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	kubeconfig := flags.String("kubeconfig", "", "Path to the kubeconfig file")
+	namespace := flags.String("n", "", "Namespace of the deployment (defaults to the current context's)")
+	flags.StringVar(namespace, "namespace", "", "Namespace of the deployment (defaults to the current context's)")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c, err := newCLI(*kubeconfig, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "enable":
+		err = c.enable(ctx, args)
+	case "disable":
+		err = c.disable(ctx, args)
+	case "status":
+		err = c.status(ctx, args)
+	case "debug":
+		err = c.debug(ctx, args)
+	case "explain":
+		err = c.explain(ctx, args)
+	case "history":
+		err = c.history(ctx, args)
+	case "rollback":
+		err = c.rollback(ctx, args)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newCLI loads the kubeconfig the same way kubectl does and builds a client that knows the tracing types
+func newCLI(kubeconfig, namespace string) (*cli, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, fmt.Errorf("failed to determine namespace: %w", err)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := tracingv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	// Recorded as the requester of debug sessions
+	var user string
+	if rawConfig, err := clientConfig.RawConfig(); err == nil {
+		if kubeContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+			user = kubeContext.AuthInfo
+		}
+	}
+
+	return &cli{client: c, namespace: namespace, user: user}, nil
+}
+
+// parseCommand parses the flags of a subcommand and returns its single positional argument
+func parseCommand(flags *flag.FlagSet, args []string, required bool) (string, error) {
+	// Allow flags after the deployment name, as kubectl does
+	var positional []string
+	for len(args) > 0 {
+		if err := flags.Parse(args); err != nil {
+			return "", err
+		}
+		args = flags.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+
+	switch {
+	case len(positional) > 1:
+		return "", fmt.Errorf("%s takes a single deployment, got %v", flags.Name(), positional)
+	case len(positional) == 1:
+		return positional[0], nil
+	case required:
+		return "", fmt.Errorf("%s needs a deployment name", flags.Name())
+	}
+	return "", nil
+}
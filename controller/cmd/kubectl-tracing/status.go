@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// resolution is what the plugin works out about one deployment
+type resolution struct {
+	// Candidates are the TracingConfigs that select the deployment
+	Candidates []*tracingv1.TracingConfig
+	// Skipped tells, per TracingConfig, why the deployment's annotations keep it from being injected
+	Skipped map[*tracingv1.TracingConfig]*tracingv1.SkippedWorkload
+	// Winner is the TracingConfig whose ConfigMap is referenced last by the first injected
+	// container; later envFrom sources take precedence over earlier ones. Nil until the deployment
+	// is injected.
+	Winner *tracingv1.TracingConfig
+	// Container is the first injected container, the one the rendered data is shown for
+	Container string
+	// Window and Override are the schedule window and debug session applied on top of the winner
+	Window   *tracingv1.SamplingWindow
+	Override *render.DebugOverride
+	// Data is the rendered configuration the deployment's pods get from the winner
+	Data map[string]string
+	// Defaulted names the TracingDefaults each defaulted spec field of the winner comes from
+	Defaulted map[string]string
+	// Workload is the winner's status entry for the deployment, if any
+	Workload *tracingv1.WorkloadStatus
+}
+
+// resolve finds the TracingConfigs selecting the deployment and renders the winner's
+// configuration the same way the controller does
+func (c *cli) resolve(ctx context.Context, deployment *appsv1.Deployment) (*resolution, error) {
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := c.client.List(ctx, &tracingConfigs); err != nil {
+		return nil, fmt.Errorf("failed to list TracingConfigs: %w", err)
+	}
+
+	result := &resolution{Skipped: map[*tracingv1.TracingConfig]*tracingv1.SkippedWorkload{}}
+	for i := range tracingConfigs.Items {
+		tracingConfig := &tracingConfigs.Items[i]
+		if !targetsNamespace(tracingConfig, deployment.Namespace) {
+			continue
+		}
+		selector := labels.Everything()
+		if tracingConfig.Spec.Selector != nil {
+			var err error
+			if selector, err = metav1.LabelSelectorAsSelector(tracingConfig.Spec.Selector); err != nil {
+				continue
+			}
+		}
+		selected, skip := render.SelectWorkload(tracingConfig, selector, deployment)
+		switch {
+		case selected:
+			result.Candidates = append(result.Candidates, tracingConfig)
+		case skip != nil:
+			result.Skipped[tracingConfig] = skip
+		}
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef == nil {
+				continue
+			}
+			for _, candidate := range result.Candidates {
+				if render.IsTracingConfigMap(envFrom.ConfigMapRef.Name, render.ConfigMapName(candidate)) {
+					result.Winner = candidate
+					result.Container = container.Name
+				}
+			}
+		}
+		if result.Winner != nil {
+			break
+		}
+	}
+	if result.Winner == nil {
+		return result, nil
+	}
+
+	var defaults tracingv1.TracingDefaultsList
+	if err := c.client.List(ctx, &defaults, client.InNamespace(result.Winner.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list tracing defaults: %w", err)
+	}
+	resolvedSpec, defaulted := render.ApplyDefaults(result.Winner.Spec, defaults.Items)
+	result.Defaulted = defaulted
+
+	active, err := render.EvaluateSchedule(resolvedSpec.Schedule, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("TracingConfig %s/%s has an invalid schedule: %w", result.Winner.Namespace, result.Winner.Name, err)
+	}
+	effectiveSpec := render.ApplyBudget(render.ApplySchedule(resolvedSpec, active), result.Winner.Status.Budget, active)
+	if limit := result.Winner.Status.MaxSamplingRate; limit != nil && effectiveSpec.SamplingRate > *limit {
+		effectiveSpec.SamplingRate = *limit
+	}
+	result.Window = active.Window
+
+	var sessions tracingv1.TracingDebugSessionList
+	if err := c.client.List(ctx, &sessions, client.InNamespace(deployment.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list debug sessions: %w", err)
+	}
+	result.Override = render.DebugOverrideFor(render.ActiveDebugSessions(sessions.Items, time.Now()), deployment)
+	result.Data = render.WithDebugOverride(render.ConfigMapData(&effectiveSpec), result.Override)
+	if render.IsServiceNameTemplate(effectiveSpec.ServiceName) {
+		serviceName, err := render.ServiceName(effectiveSpec.ServiceName, deployment)
+		if err != nil {
+			return nil, fmt.Errorf("TracingConfig %s/%s: %w", result.Winner.Namespace, result.Winner.Name, err)
+		}
+		result.Data = render.WithServiceName(result.Data, serviceName)
+	}
+	workload := render.WorkloadReference{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name}
+	env := render.ResourceAttributeEnv(&effectiveSpec, workload, result.Container)
+	result.Data = withEnv(result.Data, env[len(env)-1])
+	result.Data = render.WithSecretKeys(result.Data, render.SecretName(result.Winner), render.SecretKeys(&effectiveSpec))
+
+	for i := range result.Winner.Status.Workloads {
+		workload := &result.Winner.Status.Workloads[i]
+		if workload.Kind == "Deployment" && workload.Namespace == deployment.Namespace && workload.Name == deployment.Name {
+			result.Workload = workload
+		}
+	}
+	return result, nil
+}
+
+// withEnv returns a copy of data with the plain value of one more env var
+func withEnv(data map[string]string, env corev1.EnvVar) map[string]string {
+	merged := make(map[string]string, len(data)+1)
+	for key, value := range data {
+		merged[key] = value
+	}
+	merged[env.Name] = env.Value
+	return merged
+}
+
+// targetsNamespace reports whether the TracingConfig renders into namespace, preferring what the
+// controller last resolved over the spec
+func targetsNamespace(tracingConfig *tracingv1.TracingConfig, namespace string) bool {
+	targets := tracingConfig.Status.TargetNamespaces
+	if len(targets) == 0 {
+		spec := &tracingConfig.Spec
+		if spec.Namespace != "" {
+			targets = append(targets, spec.Namespace)
+		}
+		targets = append(targets, spec.Namespaces...)
+		if len(targets) == 0 && spec.NamespaceSelector == nil {
+			targets = []string{tracingConfig.Namespace}
+		}
+	}
+	for _, target := range targets {
+		if target == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// status prints the effective configuration of one or every deployment in the namespace
+func (c *cli) status(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	name, err := parseCommand(flags, args, false)
+	if err != nil {
+		return err
+	}
+
+	var deployments []appsv1.Deployment
+	if name != "" {
+		deployment, err := c.getDeployment(ctx, name)
+		if err != nil {
+			return err
+		}
+		deployments = append(deployments, *deployment)
+	} else {
+		var list appsv1.DeploymentList
+		if err := c.client.List(ctx, &list, client.InNamespace(c.namespace)); err != nil {
+			return fmt.Errorf("failed to list deployments: %w", err)
+		}
+		deployments = list.Items
+	}
+	sort.Slice(deployments, func(i, j int) bool { return deployments[i].Name < deployments[j].Name })
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "DEPLOYMENT\tTRACINGCONFIG\tENABLED\tSERVICE\tSAMPLING\tENDPOINT\tOVERRIDE\tPODS READY/STALE\tALSO SELECTED BY")
+	for i := range deployments {
+		deployment := &deployments[i]
+		result, err := c.resolve(ctx, deployment)
+		if err != nil {
+			return err
+		}
+		if result.Winner == nil {
+			pending := "-"
+			if len(result.Candidates) > 0 {
+				pending = "(pending)"
+			}
+			fmt.Fprintf(out, "%s\t%s\t-\t-\t-\t-\t-\t-\t%s\n", deployment.Name, pending, candidateNames(result.Candidates, nil))
+			continue
+		}
+
+		override := "-"
+		switch {
+		case result.Override != nil:
+			override = "debug:" + result.Override.Session
+		case result.Window != nil:
+			override = "window:" + result.Window.Name
+		}
+		pods := "-"
+		if result.Workload != nil {
+			pods = fmt.Sprintf("%d/%d", result.Workload.ReadyPods, result.Workload.StalePods)
+		}
+		fmt.Fprintf(out, "%s\t%s/%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			deployment.Name, result.Winner.Namespace, result.Winner.Name,
+			result.Data["OTEL_SDK_DISABLED"] != "true",
+			result.Data["OTEL_SERVICE_NAME"], result.Data["OTEL_TRACES_SAMPLER_ARG"], result.Data["OTEL_EXPORTER_OTLP_ENDPOINT"],
+			override, pods, candidateNames(result.Candidates, result.Winner))
+	}
+	return out.Flush()
+}
+
+func candidateNames(candidates []*tracingv1.TracingConfig, except *tracingv1.TracingConfig) string {
+	var names []string
+	for _, candidate := range candidates {
+		if candidate != except {
+			names = append(names, candidate.Namespace+"/"+candidate.Name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+// specFields names the TracingConfig field each rendered variable comes from
+var specFields = map[string]string{
+	"OTEL_EXPORTER_OTLP_ENDPOINT":    "spec.endpoint",
+	"OTEL_SERVICE_NAME":              "spec.serviceName",
+	"OTEL_TRACES_SAMPLER":            "always traceidratio",
+	"OTEL_TRACES_SAMPLER_ARG":        "spec.samplingRate",
+	"OTEL_SDK_DISABLED":              "spec.enabled",
+	"OTEL_EXPORTER_OTLP_PROTOCOL":    "spec.protocol",
+	"OTEL_EXPORTER_OTLP_COMPRESSION": "spec.compression",
+	"OTEL_EXPORTER_OTLP_TIMEOUT":     "spec.exportTimeout",
+	"OTEL_BSP_SCHEDULE_DELAY":        "spec.batchTimeout",
+	"OTEL_BSP_MAX_EXPORT_BATCH_SIZE": "spec.maxBatchSize",
+	"OTEL_EXPORTER_OTLP_HEADERS":     "spec.headers and spec.headersSecretName",
+}
+
+// explain prints every variable the deployment gets and where its value comes from
+func (c *cli) explain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	name, err := parseCommand(flags, args, true)
+	if err != nil {
+		return err
+	}
+	deployment, err := c.getDeployment(ctx, name)
+	if err != nil {
+		return err
+	}
+	result, err := c.resolve(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	for tracingConfig, skip := range result.Skipped {
+		fmt.Printf("TracingConfig %s/%s skips it (%s): %s.\n", tracingConfig.Namespace, tracingConfig.Name, skip.Reason, skip.Message)
+	}
+	if len(result.Candidates) == 0 {
+		fmt.Printf("Deployment %s/%s is not selected by any TracingConfig.\n", deployment.Namespace, deployment.Name)
+		return nil
+	}
+	fmt.Printf("Deployment %s/%s is selected by %s.\n", deployment.Namespace, deployment.Name, candidateNames(result.Candidates, nil))
+	if result.Winner == nil {
+		fmt.Println("None of them has injected it yet.")
+		return nil
+	}
+	fmt.Printf("TracingConfig %s/%s wins: its ConfigMap is the last envFrom source of container %s.\n", result.Winner.Namespace, result.Winner.Name, result.Container)
+	if result.Window != nil {
+		fmt.Printf("Schedule window %q is open.\n", result.Window.Name)
+	}
+	if result.Override != nil {
+		fmt.Printf("Debug session %q overrides the sampling rate.\n", result.Override.Session)
+	}
+	fmt.Println()
+
+	// Variables set explicitly on the container take precedence over every envFrom source
+	explicit := map[string]bool{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != result.Container {
+			continue
+		}
+		for _, env := range container.Env {
+			explicit[env.Name] = true
+		}
+	}
+	// The controller reports how it resolved them, and with which value
+	conflicts := map[string]tracingv1.EnvConflict{}
+	if result.Workload != nil {
+		for _, conflict := range result.Workload.EnvConflicts {
+			if conflict.Container == result.Container {
+				conflicts[conflict.Name] = conflict
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(result.Data))
+	for key := range result.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "VARIABLE\tVALUE\tSOURCE")
+	for _, key := range keys {
+		source := specFields[key]
+		switch {
+		case strings.HasPrefix(key, "OTEL_RESOURCE_ATTRIBUTES_"):
+			source = "spec.attributes"
+		case strings.HasPrefix(key, "OTEL_EXPORTER_OTLP_INSECURE"), strings.HasPrefix(key, "OTEL_EXPORTER_OTLP_CERTIFICATE"),
+			strings.HasPrefix(key, "OTEL_EXPORTER_OTLP_CLIENT_"):
+			source = "spec.tls"
+		}
+		if defaults, ok := result.Defaulted[source]; ok {
+			source = "tracingdefaults/" + defaults + " (" + source + ")"
+		}
+		value := result.Data[key]
+		conflict, conflicting := conflicts[key]
+		switch {
+		case conflicting && conflict.Resolution == tracingv1.EnvConflictRespected:
+			if source == "" {
+				source = "-"
+			}
+			value = conflict.EffectiveValue
+			source = "container env, respected (renders " + conflict.RenderedValue + " from " + source + ")"
+		case key == "OTEL_SERVICE_NAME" && render.IsServiceNameTemplate(result.Winner.Spec.ServiceName):
+			source = "spec.serviceName template " + result.Winner.Spec.ServiceName
+		case key == "OTEL_RESOURCE_ATTRIBUTES":
+			source = "spec.attributes, spec.attributesFrom* and k8s.* from the downward API"
+		case key == "OTEL_TRACES_SAMPLER_ARG" && result.Override != nil:
+			source = "debug session " + result.Override.Session
+		case key == "OTEL_TRACES_SAMPLER_ARG" && result.Window != nil && result.Window.SamplingRate != nil,
+			key == "OTEL_SDK_DISABLED" && result.Window != nil && result.Window.Enabled != nil:
+			source = "schedule window " + result.Window.Name
+		case key == "OTEL_TRACES_SAMPLER_ARG" && result.Winner.Spec.Budget != nil && result.Winner.Status.Budget != nil:
+			source = "spec.budget"
+		case explicit[key]:
+			source = "container env (overrides " + source + ")"
+		}
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\n", key, value, source)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,119 @@
+// rbac-gen writes the RBAC manifests of the tracing controller. Without -namespaces it grants a
+// ClusterRole for the cluster-wide controller; with it, one Role per namespace for a controller
+// started with the same WATCH_NAMESPACES, plus a read-only ClusterRole for the cluster-scoped
+// TracingPolicies. Both are generated from the same rules so that the two modes never drift apart:
+//
+//	go run ./cmd/rbac-gen > ../k8s/rbac-cluster.yaml
+//	go run ./cmd/rbac-gen -namespaces default > ../k8s/rbac-namespaced.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const name = "tracing-controller"
+
+// rule is one RBAC policy rule of the controller
+type rule struct {
+	apiGroup  string
+	resources []string
+	verbs     []string
+	// clusterOnly rules are for cluster-scoped resources, which a Role cannot grant
+	clusterOnly bool
+}
+
+// rules lists everything the controller reads and writes
+var rules = []rule{
+	{apiGroup: "", resources: []string{"configmaps", "secrets"}, verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	{apiGroup: "", resources: []string{"pods"}, verbs: []string{"get", "list", "watch"}},
+	{apiGroup: "", resources: []string{"events"}, verbs: []string{"create", "patch"}},
+	{apiGroup: "", resources: []string{"namespaces"}, verbs: []string{"get", "list", "watch"}, clusterOnly: true},
+	{apiGroup: "apps", resources: []string{"deployments"}, verbs: []string{"get", "list", "watch", "update", "patch"}},
+	{apiGroup: "apps", resources: []string{"replicasets"}, verbs: []string{"get", "list", "watch"}},
+	{apiGroup: "apps", resources: []string{"controllerrevisions"}, verbs: []string{"get", "list", "watch", "create", "update", "delete"}},
+	{apiGroup: "observability.kubevishwa.io", resources: []string{"tracingconfigs", "tracingdebugsessions"}, verbs: []string{"get", "list", "watch", "update", "patch"}},
+	{apiGroup: "observability.kubevishwa.io", resources: []string{"tracingdefaults"}, verbs: []string{"get", "list", "watch"}},
+	{apiGroup: "observability.kubevishwa.io", resources: []string{"tracingpolicies"}, verbs: []string{"get", "list", "watch"}, clusterOnly: true},
+	{apiGroup: "observability.kubevishwa.io", resources: []string{"tracingconfigs/status", "tracingdebugsessions/status"}, verbs: []string{"get", "update", "patch"}},
+}
+
+// policyRules let a namespace-scoped controller read the cluster-scoped TracingPolicies and the
+// labels of the namespaces they select. It reads them uncached, so nothing is watched.
+var policyRules = []rule{
+	{apiGroup: "", resources: []string{"namespaces"}, verbs: []string{"get"}},
+	{apiGroup: "observability.kubevishwa.io", resources: []string{"tracingpolicies"}, verbs: []string{"get", "list"}},
+}
+
+func main() {
+	flags := flag.NewFlagSet("rbac-gen", flag.ExitOnError)
+	namespaces := flags.String("namespaces", "", "Comma-separated namespaces the controller watches; empty for a cluster-wide controller")
+	serviceAccountNamespace := flags.String("service-account-namespace", "observability", "Namespace the controller runs in")
+	flags.Parse(os.Args[1:])
+
+	var watched []string
+	for _, namespace := range strings.Split(*namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			watched = append(watched, namespace)
+		}
+	}
+
+	out := os.Stdout
+	command := "go run ./cmd/rbac-gen"
+	if len(watched) > 0 {
+		command += " -namespaces " + strings.Join(watched, ",")
+	}
+	fmt.Fprintf(out, "# Generated by `%s` in controller/. Do not edit.\n", command)
+	writeServiceAccount(out, *serviceAccountNamespace)
+
+	if len(watched) == 0 {
+		writeRole(out, "ClusterRole", name, "", rules, true)
+		writeBinding(out, "ClusterRoleBinding", "ClusterRole", name, "", *serviceAccountNamespace)
+		return
+	}
+	for _, namespace := range watched {
+		writeRole(out, "Role", name, namespace, rules, false)
+		writeBinding(out, "RoleBinding", "Role", name, namespace, *serviceAccountNamespace)
+	}
+	writeRole(out, "ClusterRole", name+"-policies", "", policyRules, true)
+	writeBinding(out, "ClusterRoleBinding", "ClusterRole", name+"-policies", "", *serviceAccountNamespace)
+}
+
+func writeServiceAccount(out io.Writer, namespace string) {
+	fmt.Fprintf(out, "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: %s\n  namespace: %s\n", name, namespace)
+}
+
+func writeRole(out io.Writer, kind, roleName, namespace string, rules []rule, cluster bool) {
+	fmt.Fprintf(out, "---\napiVersion: rbac.authorization.k8s.io/v1\nkind: %s\nmetadata:\n  name: %s\n", kind, roleName)
+	if namespace != "" {
+		fmt.Fprintf(out, "  namespace: %s\n", namespace)
+	}
+	fmt.Fprintln(out, "rules:")
+	for _, rule := range rules {
+		if rule.clusterOnly && !cluster {
+			continue
+		}
+		fmt.Fprintf(out, "- apiGroups: [%q]\n  resources: %s\n  verbs: %s\n", rule.apiGroup, flowList(rule.resources), flowList(rule.verbs))
+	}
+}
+
+func writeBinding(out io.Writer, kind, roleKind, roleName, namespace, serviceAccountNamespace string) {
+	fmt.Fprintf(out, "---\napiVersion: rbac.authorization.k8s.io/v1\nkind: %s\nmetadata:\n  name: %s\n", kind, roleName)
+	if namespace != "" {
+		fmt.Fprintf(out, "  namespace: %s\n", namespace)
+	}
+	fmt.Fprintf(out, "roleRef:\n  apiGroup: rbac.authorization.k8s.io\n  kind: %s\n  name: %s\n", roleKind, roleName)
+	fmt.Fprintf(out, "subjects:\n- kind: ServiceAccount\n  name: %s\n  namespace: %s\n", name, serviceAccountNamespace)
+}
+
+// flowList renders a YAML flow sequence, the way the other manifests list resources and verbs
+func flowList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
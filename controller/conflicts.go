@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	// annotationRespectedEnv lists, as container/NAME pairs, the env vars a pod template kept
+	// under the Respect policy, so that they are never mistaken for ones we set ourselves
+	annotationRespectedEnv = "tracing.kubevishwa.io/respected-env"
+
+	conditionEnvConflict = "EnvConflict"
+	reasonEnvRespected   = "EnvRespected"
+	reasonEnvOverridden  = "EnvOverridden"
+	reasonNoEnvConflict  = "NoEnvConflict"
+)
+
+// envConflict is an env var set on a targeted container that takes precedence over a rendered value
+type envConflict struct {
+	container string
+	env       corev1.EnvVar
+	rendered  string
+}
+
+func (c envConflict) key() string {
+	return c.container + "/" + c.env.Name
+}
+
+// findEnvConflicts returns the OTEL_* env vars the targeted containers of template set
+// themselves for a key the configuration renders. Variables we set ourselves are not conflicts,
+// unless they were respected before. The debug sampler and service name we set are recorded per
+// container, so a value the container sets itself is never taken for ours.
+func findEnvConflicts(template *corev1.PodTemplateSpec, spec *tracingv1.TracingConfigSpec, workload render.WorkloadReference, data map[string]string, targets func(container string) bool) []envConflict {
+	respected := respectedEnv(template)
+	injected := injectedEnv(template)
+	_, injectedAttributes := template.Annotations[annotationResourceAttributes]
+
+	var conflicts []envConflict
+	for _, container := range template.Spec.Containers {
+		if !targets(container.Name) {
+			continue
+		}
+		for _, env := range container.Env {
+			if !strings.HasPrefix(env.Name, "OTEL_") {
+				continue
+			}
+			rendered, ok := data[env.Name]
+			if env.Name == "OTEL_RESOURCE_ATTRIBUTES" {
+				attributes := render.ResourceAttributeEnv(spec, workload, container.Name)
+				rendered, ok = attributes[len(attributes)-1].Value, true
+			}
+			if !ok {
+				continue
+			}
+			conflict := envConflict{container: container.Name, env: env, rendered: rendered}
+			if injected[conflict.key()] || env.Name == "OTEL_RESOURCE_ATTRIBUTES" && injectedAttributes && !respected[conflict.key()] {
+				continue
+			}
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// respectedEnv returns the container/NAME pairs recorded on the pod template
+func respectedEnv(template *corev1.PodTemplateSpec) map[string]bool {
+	respected := map[string]bool{}
+	for _, key := range strings.Split(template.Annotations[annotationRespectedEnv], ",") {
+		if key != "" {
+			respected[key] = true
+		}
+	}
+	return respected
+}
+
+// setRespectedEnv records the respected conflicts on the pod template, reporting whether the
+// annotation changed
+func setRespectedEnv(template *corev1.PodTemplateSpec, conflicts []envConflict) bool {
+	keys := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		keys = append(keys, conflict.key())
+	}
+	sort.Strings(keys)
+	value := strings.Join(keys, ",")
+
+	current, ok := template.Annotations[annotationRespectedEnv]
+	switch {
+	case value == "" && !ok:
+		return false
+	case value == "":
+		delete(template.Annotations, annotationRespectedEnv)
+		return true
+	case value == current:
+		return false
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[annotationRespectedEnv] = value
+	return true
+}
+
+// stashedEnv is an env var taken out of a container, to be put back at the same position
+type stashedEnv struct {
+	container string
+	index     int
+	env       corev1.EnvVar
+}
+
+// stashEnv takes the env vars matched by keys out of the containers, so that the ensure*
+// functions cannot touch them
+func stashEnv(template *corev1.PodTemplateSpec, keys map[string]bool) []stashedEnv {
+	var stashed []stashedEnv
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		env := container.Env[:0]
+		for j, envVar := range container.Env {
+			if keys[container.Name+"/"+envVar.Name] {
+				stashed = append(stashed, stashedEnv{container: container.Name, index: j, env: envVar})
+				continue
+			}
+			env = append(env, envVar)
+		}
+		container.Env = env
+	}
+	return stashed
+}
+
+// restoreEnv puts stashed env vars back where they were, or at the end if the env got shorter
+func restoreEnv(template *corev1.PodTemplateSpec, stashed []stashedEnv) {
+	for _, s := range stashed {
+		for i := range template.Spec.Containers {
+			container := &template.Spec.Containers[i]
+			if container.Name != s.container {
+				continue
+			}
+			index := s.index
+			if index > len(container.Env) {
+				index = len(container.Env)
+			}
+			container.Env = append(container.Env[:index], append([]corev1.EnvVar{s.env}, container.Env[index:]...)...)
+		}
+	}
+}
+
+// envValue describes the value of an env var, without resolving references to other objects
+func envValue(env corev1.EnvVar) string {
+	from := env.ValueFrom
+	switch {
+	case from == nil:
+		return env.Value
+	case from.SecretKeyRef != nil:
+		return fmt.Sprintf("<secret %s key %s>", from.SecretKeyRef.Name, from.SecretKeyRef.Key)
+	case from.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("<configmap %s key %s>", from.ConfigMapKeyRef.Name, from.ConfigMapKeyRef.Key)
+	case from.FieldRef != nil:
+		return fmt.Sprintf("<field %s>", from.FieldRef.FieldPath)
+	case from.ResourceFieldRef != nil:
+		return fmt.Sprintf("<resource %s>", from.ResourceFieldRef.Resource)
+	}
+	return "<valueFrom>"
+}
+
+// envConflictStatus reports the conflicts with their resolution and effective value
+func envConflictStatus(conflicts []envConflict, resolution string) []tracingv1.EnvConflict {
+	var status []tracingv1.EnvConflict
+	for _, conflict := range conflicts {
+		effective := envValue(conflict.env)
+		if resolution == tracingv1.EnvConflictOverridden {
+			effective = conflict.rendered
+		}
+		status = append(status, tracingv1.EnvConflict{
+			Container:      conflict.container,
+			Name:           conflict.env.Name,
+			Resolution:     resolution,
+			EffectiveValue: effective,
+			RenderedValue:  conflict.rendered,
+		})
+	}
+	return status
+}
+
+// describeEnvConflicts lists the conflicts as container/NAME pairs for messages
+func describeEnvConflicts(conflicts []envConflict) string {
+	keys := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		keys = append(keys, conflict.key())
+	}
+	return strings.Join(keys, ", ")
+}
+
+// reportEnvConflicts sets the EnvConflict condition from the conflicts found on the workloads
+func reportEnvConflicts(tracingConfig *tracingv1.TracingConfig, workloads []tracingv1.WorkloadStatus) {
+	respected, overridden := 0, 0
+	var names []string
+	for _, workload := range workloads {
+		if len(workload.EnvConflicts) == 0 {
+			continue
+		}
+		for _, conflict := range workload.EnvConflicts {
+			if conflict.Resolution == tracingv1.EnvConflictRespected {
+				respected++
+			} else {
+				overridden++
+			}
+		}
+		if len(names) < maxDriftMessageItems {
+			names = append(names, fmt.Sprintf("%s %s/%s", workload.Kind, workload.Namespace, workload.Name))
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               conditionEnvConflict,
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonNoEnvConflict,
+		Message:            "No container sets OTEL_* env vars of its own",
+		ObservedGeneration: tracingConfig.Generation,
+	}
+	switch {
+	case respected > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonEnvRespected
+		condition.Message = fmt.Sprintf("%d env vars set on containers shadow the rendered configuration: %s", respected, strings.Join(names, ", "))
+	case overridden > 0:
+		condition.Reason = reasonEnvOverridden
+		condition.Message = fmt.Sprintf("%d env vars set on containers were overridden: %s", overridden, strings.Join(names, ", "))
+	}
+	meta.SetStatusCondition(&tracingConfig.Status.Conditions, condition)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+func TestFindEnvConflicts(t *testing.T) {
+	data := map[string]string{"OTEL_TRACES_SAMPLER_ARG": "0.1", "OTEL_SERVICE_NAME": "checkout"}
+	workload := render.WorkloadReference{Kind: "Deployment", Namespace: "default", Name: "checkout"}
+
+	tests := []struct {
+		name        string
+		containers  []corev1.Container
+		annotations map[string]string
+		want        []string
+	}{
+		{
+			name:       "own value for a rendered key",
+			containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "OTEL_TRACES_SAMPLER_ARG", Value: "1"}}}},
+			want:       []string{"app/OTEL_TRACES_SAMPLER_ARG"},
+		},
+		{
+			name:       "key that is not rendered",
+			containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "OTEL_LOG_LEVEL", Value: "debug"}, {Name: "LOG_LEVEL", Value: "debug"}}}},
+		},
+		{
+			name:        "value we set ourselves",
+			containers:  []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "OTEL_SERVICE_NAME", Value: "checkout"}}}},
+			annotations: map[string]string{annotationInjectedEnv: "app/OTEL_SERVICE_NAME"},
+		},
+		{
+			name: "value we set on another container",
+			containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{{Name: "OTEL_SERVICE_NAME", Value: "checkout"}}},
+				{Name: "worker", Env: []corev1.EnvVar{{Name: "OTEL_SERVICE_NAME", Value: "worker"}}},
+			},
+			annotations: map[string]string{annotationInjectedEnv: "app/OTEL_SERVICE_NAME"},
+			want:        []string{"worker/OTEL_SERVICE_NAME"},
+		},
+		{
+			name:       "container that is not targeted",
+			containers: []corev1.Container{{Name: "istio-proxy", Env: []corev1.EnvVar{{Name: "OTEL_SERVICE_NAME", Value: "proxy"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &tracingv1.TracingConfigSpec{}
+			template := &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       corev1.PodSpec{Containers: tt.containers},
+			}
+			targets := func(container string) bool { return render.TargetsContainer(spec, container) }
+
+			var got []string
+			for _, conflict := range findEnvConflicts(template, spec, workload, data, targets) {
+				got = append(got, conflict.key())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findEnvConflicts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectDeploymentEnvConflictPolicy(t *testing.T) {
+	data := map[string]string{"OTEL_TRACES_SAMPLER_ARG": "0.1"}
+
+	tests := []struct {
+		name       string
+		policy     string
+		env        []corev1.EnvVar
+		resolution string
+		effective  string
+		respected  string
+		wantReason string
+	}{
+		{name: "default", env: []corev1.EnvVar{{Name: "OTEL_TRACES_SAMPLER_ARG", Value: "1"}}, resolution: tracingv1.EnvConflictRespected, effective: "1", respected: "app/OTEL_TRACES_SAMPLER_ARG"},
+		{name: "respect", policy: tracingv1.EnvConflictRespect, env: []corev1.EnvVar{{Name: "OTEL_TRACES_SAMPLER_ARG", Value: "1"}}, resolution: tracingv1.EnvConflictRespected, effective: "1", respected: "app/OTEL_TRACES_SAMPLER_ARG"},
+		{name: "override", policy: tracingv1.EnvConflictOverride, resolution: tracingv1.EnvConflictOverridden, effective: "0.1"},
+		{name: "fail", policy: tracingv1.EnvConflictFail, wantReason: "EnvConflict"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "app",
+					Env:  []corev1.EnvVar{{Name: "OTEL_TRACES_SAMPLER_ARG", Value: "1"}},
+				}}}}},
+			}
+			scheme := runtime.NewScheme()
+			if err := appsv1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			r := &TracingConfigReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build()}
+			tracingConfig := &tracingv1.TracingConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+				Spec:       tracingv1.TracingConfigSpec{EnvConflictPolicy: tt.policy},
+			}
+
+			_, conflicts, err := r.injectDeployment(context.Background(), tracingConfig, deployment, "checkout-tracing-config", "", "rev-1", "hash-1", data, nil, "")
+			if tt.wantReason != "" {
+				if err == nil || errorReason(err) != tt.wantReason {
+					t.Fatalf("injectDeployment() = %v, want %s", err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 || conflicts[0].Resolution != tt.resolution || conflicts[0].EffectiveValue != tt.effective || conflicts[0].RenderedValue != "0.1" {
+				t.Fatalf("conflicts = %+v, want one %s with effective value %q", conflicts, tt.resolution, tt.effective)
+			}
+			template := deployment.Spec.Template
+			var env []corev1.EnvVar
+			for _, envVar := range template.Spec.Containers[0].Env {
+				if envVar.Name == "OTEL_TRACES_SAMPLER_ARG" {
+					env = append(env, envVar)
+				}
+			}
+			if !reflect.DeepEqual(env, tt.env) {
+				t.Errorf("OTEL_TRACES_SAMPLER_ARG env = %v, want %v", env, tt.env)
+			}
+			if respected := template.Annotations[annotationRespectedEnv]; respected != tt.respected {
+				t.Errorf("respected env = %q, want %q", respected, tt.respected)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// TracingDebugSessionReconciler tracks the lifetime of debug sessions. The override itself is
+// applied by the TracingConfigReconciler, which watches the sessions' phase.
+type TracingDebugSessionReconciler struct {
+	client.Client
+}
+
+func (r *TracingDebugSessionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "ReconcileDebugSession")
+	defer span.End()
+
+	var session tracingv1.TracingDebugSession
+	if err := r.Get(ctx, req.NamespacedName, &session); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if session.Status.Phase == tracingv1.DebugSessionExpired || session.Status.Phase == tracingv1.DebugSessionFailed {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	if session.Status.ExpiresAt == nil {
+		duration, err := time.ParseDuration(session.Spec.Duration)
+		if err != nil || duration <= 0 {
+			session.Status.Phase = tracingv1.DebugSessionFailed
+			session.Status.Message = fmt.Sprintf("Invalid duration %q", session.Spec.Duration)
+			return ctrl.Result{}, r.Status().Update(ctx, &session)
+		}
+		if _, err := metav1.LabelSelectorAsSelector(session.Spec.Selector); err != nil || session.Spec.Selector == nil {
+			session.Status.Phase = tracingv1.DebugSessionFailed
+			session.Status.Message = fmt.Sprintf("Invalid selector: %v", err)
+			return ctrl.Result{}, r.Status().Update(ctx, &session)
+		}
+
+		requestedAt := session.CreationTimestamp
+		expiresAt := metav1.NewTime(requestedAt.Add(duration))
+		session.Status.RequestedAt = &requestedAt
+		session.Status.ExpiresAt = &expiresAt
+		session.Status.RequestedBy = session.Annotations[tracingv1.AnnotationRequestedBy]
+	}
+
+	if !now.Before(session.Status.ExpiresAt.Time) {
+		session.Status.Phase = tracingv1.DebugSessionExpired
+		session.Status.Message = fmt.Sprintf("Expired at %s, sampling reverted", session.Status.ExpiresAt.Format(time.RFC3339))
+		log.Printf("Debug session %s/%s expired", session.Namespace, session.Name)
+		return ctrl.Result{}, r.Status().Update(ctx, &session)
+	}
+
+	session.Status.Phase = tracingv1.DebugSessionActive
+	session.Status.Message = fmt.Sprintf("Sampling at %s until %s", render.FormatSamplingRate(session.EffectiveSamplingRate()), session.Status.ExpiresAt.Format(time.RFC3339))
+	if err := r.Status().Update(ctx, &session); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: time.Until(session.Status.ExpiresAt.Time) + time.Second}, nil
+}
+
+func (r *TracingDebugSessionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tracingv1.TracingDebugSession{}).
+		Complete(r)
+}
+
+// activeDebugSessions returns the sessions in the namespace that are currently active,
+// sorted by name so that overlapping sessions resolve the same way on every reconcile
+func (r *TracingConfigReconciler) activeDebugSessions(ctx context.Context, namespace string) ([]tracingv1.TracingDebugSession, error) {
+	var sessions tracingv1.TracingDebugSessionList
+	if err := r.List(ctx, &sessions, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list debug sessions: %w", err)
+	}
+
+	return render.ActiveDebugSessions(sessions.Items, time.Now()), nil
+}
+
+// ensureDebugOverride sets, or removes once the session is gone, the explicit sampler env var
+// that takes precedence over the ConfigMap on the containers matched by targets. It reports
+// whether the template was modified.
+func ensureDebugOverride(template *corev1.PodTemplateSpec, override *render.DebugOverride, targets func(container string) bool) bool {
+	current := template.Annotations[tracingv1.AnnotationDebugSession]
+	if override == nil && current == "" {
+		return false
+	}
+
+	updated := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if override == nil || !targets(container.Name) {
+			if unsetInjectedEnv(template, container, "OTEL_TRACES_SAMPLER_ARG") {
+				updated = true
+			}
+			continue
+		}
+		if setInjectedEnv(template, container, corev1.EnvVar{Name: "OTEL_TRACES_SAMPLER_ARG", Value: render.FormatSamplingRate(override.SamplingRate)}) {
+			updated = true
+		}
+	}
+
+	switch {
+	case override == nil:
+		delete(template.Annotations, tracingv1.AnnotationDebugSession)
+		updated = true
+	case current != override.Session:
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[tracingv1.AnnotationDebugSession] = override.Session
+		updated = true
+	}
+	return updated
+}
+
+// tracingConfigsForDebugSession maps a debug session event to the TracingConfigs that
+// target the session's namespace
+func (r *TracingConfigReconciler) tracingConfigsForDebugSession(obj client.Object) []reconcile.Request {
+	return r.tracingConfigsTargeting(context.Background(), obj.GetNamespace())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// applyDefaults merges the TracingDefaults of the TracingConfig's namespace into its spec and
+// reports the result, and where each defaulted field came from, in status. Only the in-memory copy changes, the stored spec stays as written.
+func (r *TracingConfigReconciler) applyDefaults(ctx context.Context, tracingConfig *tracingv1.TracingConfig) error {
+	var defaults tracingv1.TracingDefaultsList
+	if err := r.List(ctx, &defaults, client.InNamespace(tracingConfig.Namespace)); err != nil {
+		return fmt.Errorf("failed to list tracing defaults: %w", err)
+	}
+
+	resolved, origins := render.ApplyDefaults(tracingConfig.Spec, defaults.Items)
+	tracingConfig.Spec = resolved
+	tracingConfig.Status.DefaultedFields = render.DefaultedFields(origins)
+
+	tracingConfig.Status.ResolvedSpec = new(tracingv1.TracingConfigSpec)
+	resolved.DeepCopyInto(tracingConfig.Status.ResolvedSpec)
+	tracingConfig.Status.Defaults = nil
+	for _, item := range defaults.Items {
+		tracingConfig.Status.Defaults = append(tracingConfig.Status.Defaults, item.Name)
+	}
+	sort.Strings(tracingConfig.Status.Defaults)
+
+	if resolved.Endpoint == "" {
+		return terminal("MissingEndpoint", errors.New("spec.endpoint is not set and no TracingDefaults in the namespace provide one"))
+	}
+	return nil
+}
+
+// tracingConfigsForDefaults maps a TracingDefaults change to every TracingConfig in its namespace
+func (r *TracingConfigReconciler) tracingConfigsForDefaults(obj client.Object) []reconcile.Request {
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := r.List(context.Background(), &tracingConfigs, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Printf("Failed to list TracingConfigs for defaults %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tracingConfig := range tracingConfigs.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: tracingConfig.Namespace, Name: tracingConfig.Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+const (
+	conditionDrifted     = "Drifted"
+	reasonDriftCorrected = "DriftCorrected"
+	reasonDriftDetected  = "DriftDetected"
+	reasonNoDrift        = "NoDrift"
+
+	// maxDriftMessageItems caps how many resources are listed in the condition message
+	maxDriftMessageItems = 5
+)
+
+// diffConfigMapData describes, one line per key, how the live data differs from the desired data
+func diffConfigMapData(live, desired map[string]string) []string {
+	var diff []string
+	for key, want := range desired {
+		got, ok := live[key]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("-%s", key))
+		case got != want:
+			diff = append(diff, fmt.Sprintf("%s: %q -> %q", key, got, want))
+		}
+	}
+	for key := range live {
+		if _, ok := desired[key]; !ok {
+			diff = append(diff, fmt.Sprintf("+%s", key))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// reportDrift records the drifted resources in status, sets the Drifted condition and emits
+// one event per resource whose drift was not already reported
+func (r *TracingConfigReconciler) reportDrift(tracingConfig *tracingv1.TracingConfig, drift []tracingv1.DriftReport) {
+	reported := tracingConfig.Status.Drift
+	tracingConfig.Status.Drift = drift
+	if len(drift) == 0 {
+		meta.SetStatusCondition(&tracingConfig.Status.Conditions, metav1.Condition{
+			Type:               conditionDrifted,
+			Status:             metav1.ConditionFalse,
+			Reason:             reasonNoDrift,
+			Message:            "Live resources match the rendered configuration",
+			ObservedGeneration: tracingConfig.Generation,
+		})
+		return
+	}
+
+	reason, action := reasonDriftCorrected, "reverted"
+	if !tracingConfig.Spec.EnforcesDrift() {
+		reason, action = reasonDriftDetected, "left in place"
+	}
+
+	var names []string
+	for _, report := range drift {
+		if len(names) < maxDriftMessageItems {
+			names = append(names, fmt.Sprintf("%s %s/%s", report.Kind, report.Namespace, report.Name))
+		}
+		// A resource that stays drifted the same way is reported once, not on every reconcile
+		if driftReported(reported, report) {
+			continue
+		}
+		driftEvents.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name, report.Kind).Inc()
+		log.Printf("%s %s/%s drifted from TracingConfig %s/%s (%s): %s", report.Kind, report.Namespace, report.Name,
+			tracingConfig.Namespace, tracingConfig.Name, action, strings.Join(report.Diff, "; "))
+		if r.Recorder != nil {
+			r.Recorder.Eventf(tracingConfig, corev1.EventTypeWarning, conditionDrifted, "%s %s/%s drifted (%s): %s",
+				report.Kind, report.Namespace, report.Name, action, strings.Join(report.Diff, "; "))
+		}
+	}
+	if len(drift) > maxDriftMessageItems {
+		names = append(names, fmt.Sprintf("and %d more", len(drift)-maxDriftMessageItems))
+	}
+
+	meta.SetStatusCondition(&tracingConfig.Status.Conditions, metav1.Condition{
+		Type:               conditionDrifted,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            fmt.Sprintf("%d resources drifted, %s: %s", len(drift), action, strings.Join(names, ", ")),
+		ObservedGeneration: tracingConfig.Generation,
+	})
+}
+
+// driftReported reports whether the same drift of the same resource is already in the list
+func driftReported(reported []tracingv1.DriftReport, report tracingv1.DriftReport) bool {
+	for _, previous := range reported {
+		if equality.Semantic.DeepEqual(previous, report) {
+			return true
+		}
+	}
+	return false
+}
+
+// tracingConfigsForConfigMap maps a change to a rendered ConfigMap back to its TracingConfig
+func (r *TracingConfigReconciler) tracingConfigsForConfigMap(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels[labelManagedBy] != managedByValue || labels[labelConfigName] == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: labels[labelConfigNamespace], Name: labels[labelConfigName]},
+	}}
+}
+
+// tracingConfigsForDeployment maps a Deployment change to every TracingConfig targeting its
+// namespace, so that edits to injected workloads are noticed right away
+func (r *TracingConfigReconciler) tracingConfigsForDeployment(obj client.Object) []reconcile.Request {
+	return r.tracingConfigsTargeting(context.Background(), obj.GetNamespace())
+}
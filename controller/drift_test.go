@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+func TestReportDriftEmitsChangesOnly(t *testing.T) {
+	configMap := tracingv1.DriftReport{Kind: "ConfigMap", Namespace: "default", Name: "otel-config", Diff: []string{`OTEL_TRACES_SAMPLER_ARG: "1" -> "0.5"`}}
+	deployment := tracingv1.DriftReport{Kind: "Deployment", Namespace: "default", Name: "checkout", Diff: []string{"-OTEL_SERVICE_NAME"}}
+	edited := configMap
+	edited.Diff = []string{`OTEL_TRACES_SAMPLER_ARG: "0.1" -> "0.5"`}
+
+	tests := []struct {
+		name     string
+		reported []tracingv1.DriftReport
+		drift    []tracingv1.DriftReport
+		events   int
+	}{
+		{name: "new drift", drift: []tracingv1.DriftReport{configMap}, events: 1},
+		{name: "unchanged", reported: []tracingv1.DriftReport{configMap}, drift: []tracingv1.DriftReport{configMap}},
+		{name: "another resource", reported: []tracingv1.DriftReport{configMap}, drift: []tracingv1.DriftReport{configMap, deployment}, events: 1},
+		{name: "edited again", reported: []tracingv1.DriftReport{configMap}, drift: []tracingv1.DriftReport{edited}, events: 1},
+		{name: "drift gone", reported: []tracingv1.DriftReport{configMap}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &TracingConfigReconciler{Recorder: recorder}
+			tracingConfig := &tracingv1.TracingConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+				Status:     tracingv1.TracingConfigStatus{Drift: tt.reported},
+			}
+
+			r.reportDrift(tracingConfig, tt.drift)
+
+			if events := len(recorder.Events); events != tt.events {
+				t.Errorf("emitted %d events, want %d", events, tt.events)
+			}
+			if len(tracingConfig.Status.Drift) != len(tt.drift) {
+				t.Errorf("status drift = %v, want %v", tracingConfig.Status.Drift, tt.drift)
+			}
+		})
+	}
+}
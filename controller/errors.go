@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	conditionReady = "Ready"
+
+	reasonReconciled      = "Reconciled"
+	reasonWorkloadsFailed = "WorkloadsFailed"
+)
+
+type errorClass string
+
+const (
+	// errorClassTerminal errors come from the TracingConfig itself; retrying cannot fix them
+	errorClassTerminal errorClass = "Terminal"
+	// errorClassTransient errors are expected to go away (conflicts, throttling, timeouts)
+	errorClassTransient errorClass = "Transient"
+)
+
+// terminalError marks an error that needs a change to the TracingConfig before it can succeed
+type terminalError struct {
+	Reason string
+	Err    error
+}
+
+func (e *terminalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *terminalError) Unwrap() error {
+	return e.Err
+}
+
+// terminal wraps err as a terminal error with a condition reason
+func terminal(reason string, err error) error {
+	return &terminalError{Reason: reason, Err: err}
+}
+
+// classifyError decides whether a failed reconcile should be retried
+func classifyError(err error) errorClass {
+	var te *terminalError
+	if errors.As(err, &te) {
+		return errorClassTerminal
+	}
+
+	// The API server rejected the object we sent; sending it again won't help
+	switch {
+	case apierrors.IsInvalid(err),
+		apierrors.IsBadRequest(err),
+		apierrors.IsRequestEntityTooLargeError(err),
+		apierrors.IsMethodNotSupported(err):
+		return errorClassTerminal
+	}
+	return errorClassTransient
+}
+
+// errorReason returns a CamelCase reason for err, suitable for conditions and metric labels
+func errorReason(err error) string {
+	var te *terminalError
+	if errors.As(err, &te) {
+		return te.Reason
+	}
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	return "TransientError"
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	reasonRolledBack       = "RolledBack"
+	reasonRollbackNotFound = "RollbackRevisionNotFound"
+)
+
+// listRevisions returns the revisions recorded for the TracingConfig, oldest first
+func (r *TracingConfigReconciler) listRevisions(ctx context.Context, tracingConfig *tracingv1.TracingConfig) ([]*appsv1.ControllerRevision, error) {
+	var revisions appsv1.ControllerRevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(tracingConfig.Namespace), client.MatchingLabels{labelConfigName: tracingConfig.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	return render.OwnedRevisions(tracingConfig, revisions.Items), nil
+}
+
+// recordRevision records the resolved spec as the newest revision of the TracingConfig's history and
+// returns its number. A spec recorded before keeps its revision, which is renumbered as the
+// newest, the way Deployments treat their ReplicaSets. The history is then pruned to its limit.
+func (r *TracingConfigReconciler) recordRevision(ctx context.Context, tracingConfig *tracingv1.TracingConfig, spec *tracingv1.TracingConfigSpec) (number int64, err error) {
+	ctx, span := startSpan(ctx, "RecordRevision")
+	defer func() {
+		span.SetAttributes(attribute.Int64("tracingconfig.revision", number))
+		endSpan(span, err)
+	}()
+
+	encoded, err := render.RevisionSpec(spec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode spec: %w", err)
+	}
+	revisions, err := r.listRevisions(ctx, tracingConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	if len(revisions) > 0 {
+		latest = revisions[len(revisions)-1].Revision
+	}
+	name := render.SpecRevisionName(tracingConfig, encoded)
+	var current *appsv1.ControllerRevision
+	for _, revision := range revisions {
+		if revision.Name == name {
+			current = revision
+		}
+	}
+
+	switch {
+	case current != nil && current.Revision == latest:
+	case current != nil:
+		current.Revision = latest + 1
+		if err := r.Update(ctx, current); err != nil {
+			return 0, fmt.Errorf("failed to renumber revision %s: %w", current.Name, err)
+		}
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	default:
+		current = &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       tracingConfig.Namespace,
+				Labels:          configMapLabels(tracingConfig),
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(tracingConfig, tracingv1.GroupVersion.WithKind("TracingConfig"))},
+			},
+			Data:     runtime.RawExtension{Raw: encoded},
+			Revision: latest + 1,
+		}
+		if err := r.Create(ctx, current); err != nil {
+			return 0, fmt.Errorf("failed to create revision %s: %w", name, err)
+		}
+		log.Printf("Recorded revision %d of TracingConfig %s/%s", current.Revision, tracingConfig.Namespace, tracingConfig.Name)
+		revisions = append(revisions, current)
+	}
+
+	// The current revision is always kept
+	for i := 0; i < len(revisions)-tracingConfig.Spec.HistoryLimit(); i++ {
+		if revisions[i] == current {
+			continue
+		}
+		if err := r.Delete(ctx, revisions[i]); client.IgnoreNotFound(err) != nil {
+			return 0, fmt.Errorf("failed to prune revision %s: %w", revisions[i].Name, err)
+		}
+	}
+	return current.Revision, nil
+}
+
+// rollback replaces the spec with the resolved one recorded in the revision spec.rollbackTo
+// names, which sets explicitly every field the TracingDefaults filled in then.
+// Updating the spec triggers the reconcile that renders it and records it as the newest
+// revision. A revision that cannot be restored is reported and the request dropped.
+func (r *TracingConfigReconciler) rollback(ctx context.Context, tracingConfig *tracingv1.TracingConfig) (result ctrl.Result, err error) {
+	requested := tracingConfig.Spec.RollbackTo.Revision
+	ctx, span := startSpan(ctx, "Rollback", attribute.Int64("tracingconfig.revision", requested))
+	defer func() { endSpan(span, err) }()
+
+	revisions, err := r.listRevisions(ctx, tracingConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var spec tracingv1.TracingConfigSpec
+	target := render.RollbackTarget(revisions, tracingConfig.Status.CurrentRevision, requested)
+	if target == nil {
+		err = fmt.Errorf("revision %d not found", requested)
+		if requested == 0 {
+			err = fmt.Errorf("no revision before %d", tracingConfig.Status.CurrentRevision)
+		}
+	} else {
+		spec, err = render.SpecFromRevision(target)
+	}
+	if err != nil {
+		log.Printf("Cannot roll back TracingConfig %s/%s: %v", tracingConfig.Namespace, tracingConfig.Name, err)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(tracingConfig, corev1.EventTypeWarning, reasonRollbackNotFound, "Cannot roll back: %v", err)
+		}
+		tracingConfig.Spec.RollbackTo = nil
+		return ctrl.Result{}, r.Update(ctx, tracingConfig)
+	}
+
+	tracingConfig.Spec = spec
+	if err := r.Update(ctx, tracingConfig); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to roll back to revision %d: %w", target.Revision, err)
+	}
+	log.Printf("Rolled back TracingConfig %s/%s to revision %d", tracingConfig.Namespace, tracingConfig.Name, target.Revision)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(tracingConfig, corev1.EventTypeNormal, reasonRolledBack, "Rolled back to revision %d", target.Revision)
+	}
+	return ctrl.Result{}, nil
+}
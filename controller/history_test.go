@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// historyReconciler serves the TracingConfig and its revisions from a fake client
+func historyReconciler(t *testing.T, tracingConfig *tracingv1.TracingConfig) (*TracingConfigReconciler, *record.FakeRecorder) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracingv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	recorder := record.NewFakeRecorder(10)
+	return &TracingConfigReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(tracingConfig).Build(),
+		Recorder: recorder,
+	}, recorder
+}
+
+func TestRecordRevision(t *testing.T) {
+	limit := int32(2)
+	tracingConfig := &tracingv1.TracingConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout", UID: "uid-1"},
+		Spec:       tracingv1.TracingConfigSpec{RevisionHistoryLimit: &limit},
+	}
+	r, _ := historyReconciler(t, tracingConfig)
+	spec := func(rate float64) *tracingv1.TracingConfigSpec {
+		return &tracingv1.TracingConfigSpec{ServiceName: "checkout", SamplingRate: rate}
+	}
+
+	steps := []struct {
+		name     string
+		spec     *tracingv1.TracingConfigSpec
+		want     int64
+		recorded []float64
+	}{
+		{name: "first spec", spec: spec(0.1), want: 1, recorded: []float64{0.1}},
+		{name: "same spec again", spec: spec(0.1), want: 1, recorded: []float64{0.1}},
+		{name: "new spec", spec: spec(0.2), want: 2, recorded: []float64{0.1, 0.2}},
+		{name: "earlier spec is renumbered", spec: spec(0.1), want: 3, recorded: []float64{0.2, 0.1}},
+		{name: "history is pruned to the limit", spec: spec(0.3), want: 4, recorded: []float64{0.1, 0.3}},
+	}
+	for _, step := range steps {
+		number, err := r.recordRevision(context.Background(), tracingConfig, step.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if number != step.want {
+			t.Errorf("%s: revision %d, want %d", step.name, number, step.want)
+		}
+		revisions, err := r.listRevisions(context.Background(), tracingConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var recorded []float64
+		for _, revision := range revisions {
+			decoded, err := render.SpecFromRevision(revision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded = append(recorded, decoded.SamplingRate)
+		}
+		if len(recorded) != len(step.recorded) {
+			t.Fatalf("%s: recorded rates %v, want %v", step.name, recorded, step.recorded)
+		}
+		for i := range recorded {
+			if recorded[i] != step.recorded[i] {
+				t.Errorf("%s: recorded rates %v, want %v", step.name, recorded, step.recorded)
+				break
+			}
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	limit := int32(2)
+	tests := []struct {
+		name     string
+		revision int64
+		want     float64
+		reason   string
+	}{
+		{name: "previous revision", want: 0.2, reason: reasonRolledBack},
+		{name: "named revision", revision: 2, want: 0.2, reason: reasonRolledBack},
+		{name: "pruned revision", revision: 1, want: 0.3, reason: reasonRollbackNotFound},
+		{name: "unknown revision", revision: 7, want: 0.3, reason: reasonRollbackNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracingConfig := &tracingv1.TracingConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout", UID: "uid-1"},
+				Spec:       tracingv1.TracingConfigSpec{ServiceName: "checkout", SamplingRate: 0.3, RevisionHistoryLimit: &limit},
+			}
+			r, recorder := historyReconciler(t, tracingConfig)
+			ctx := context.Background()
+
+			// Revision 1 falls out of the history when revision 3 is recorded
+			for _, rate := range []float64{0.1, 0.2, 0.3} {
+				spec := tracingConfig.Spec
+				spec.SamplingRate = rate
+				if _, err := r.recordRevision(ctx, tracingConfig, &spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			key := client.ObjectKeyFromObject(tracingConfig)
+			if err := r.Get(ctx, key, tracingConfig); err != nil {
+				t.Fatal(err)
+			}
+			tracingConfig.Status.CurrentRevision = 3
+			tracingConfig.Spec.RollbackTo = &tracingv1.RollbackConfig{Revision: tt.revision}
+			if _, err := r.rollback(ctx, tracingConfig); err != nil {
+				t.Fatal(err)
+			}
+
+			var got tracingv1.TracingConfig
+			if err := r.Get(ctx, key, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Spec.RollbackTo != nil || got.Spec.SamplingRate != tt.want {
+				t.Errorf("spec = rate %g, rollbackTo %v, want rate %g and the request cleared", got.Spec.SamplingRate, got.Spec.RollbackTo, tt.want)
+			}
+			if event := <-recorder.Events; !strings.Contains(event, tt.reason) {
+				t.Errorf("event = %q, want %s", event, tt.reason)
+			}
+		})
+	}
+}
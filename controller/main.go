@@ -4,329 +4,861 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
 )
 
-// TracingConfig represents our custom resource
-type TracingConfig struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata,omitempty"`
-	Spec              TracingConfigSpec   `json:"spec,omitempty"`
-	Status            TracingConfigStatus `json:"status,omitempty"`
-}
+// TracingConfigReconciler reconciles TracingConfig objects
+type TracingConfigReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	K8sClient kubernetes.Interface
+	Recorder  record.EventRecorder
 
-type TracingConfigSpec struct {
-	Enabled       bool                  `json:"enabled"`
-	SamplingRate  float64               `json:"samplingRate,omitempty"`
-	Endpoint      string                `json:"endpoint"`
-	ServiceName   string                `json:"serviceName"`
-	Namespace     string                `json:"namespace,omitempty"`
-	Selector      *metav1.LabelSelector `json:"selector,omitempty"`
-	Headers       map[string]string     `json:"headers,omitempty"`
-	Attributes    map[string]string     `json:"attributes,omitempty"`
-	ExportTimeout string                `json:"exportTimeout,omitempty"`
-	BatchTimeout  string                `json:"batchTimeout,omitempty"`
-	MaxBatchSize  int                   `json:"maxBatchSize,omitempty"`
-}
+	// Probes checks the OTLP endpoints of the enabled TracingConfigs; nil disables probing
+	Probes *EndpointProbes
 
-type TracingConfigStatus struct {
-	Phase      string       `json:"phase,omitempty"`
-	Message    string       `json:"message,omitempty"`
-	AppliedAt  *metav1.Time `json:"appliedAt,omitempty"`
-	TargetPods []string     `json:"targetPods,omitempty"`
-}
+	// WatchNamespaces restricts the controller to these namespaces, so that it works with Roles
+	// instead of a ClusterRole; empty means cluster-wide
+	WatchNamespaces []string
 
-type TracingConfigList struct {
-	metav1.TypeMeta `json:",inline"`
-	metav1.ListMeta `json:"metadata,omitempty"`
-	Items           []TracingConfig `json:"items"`
-}
+	// APIReader reads around the cache: the Secrets users reference, and the TracingPolicies of a
+	// namespace-scoped controller
+	APIReader client.Reader
+	// SourceSecrets holds the metadata of every Secret, to notice when one users reference
+	// changes without caching its data; nil leaves rotations to the periodic requeue
+	SourceSecrets cache.Cache
 
-// DeepCopyObject implements runtime.Object interface
-func (tc *TracingConfig) DeepCopyObject() runtime.Object {
-	if tc == nil {
-		return nil
-	}
-	out := new(TracingConfig)
-	tc.DeepCopyInto(out)
-	return out
+	// Throughput reports the span throughput span budgets are checked against; nil leaves
+	// budgeted sampling rates where they are
+	Throughput SpanThroughputSource
 }
 
-// DeepCopyInto copies all properties of this object into another object of the same type
-func (tc *TracingConfig) DeepCopyInto(out *TracingConfig) {
-	*out = *tc
-	out.TypeMeta = tc.TypeMeta
-	tc.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	tc.Spec.DeepCopyInto(&out.Spec)
-	tc.Status.DeepCopyInto(&out.Status)
-}
+func (r *TracingConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := startSpan(ctx, "Reconcile",
+		semconv.K8SNamespaceNameKey.String(req.Namespace),
+		attribute.String("tracingconfig.name", req.Name),
+	)
+	start := time.Now()
+	result, err := r.reconcile(ctx, req)
+	endSpan(span, err)
+
+	outcome := reconcileOutcomeSuccess
+	if err != nil {
+		outcome = string(classifyError(err))
+	}
+	reconcileDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
 
-// DeepCopy creates a deep copy of the TracingConfig
-func (tc *TracingConfig) DeepCopy() *TracingConfig {
-	if tc == nil {
-		return nil
+	// Terminal errors are not retried with backoff, anything else is. They are still rechecked on
+	// the periodic requeue, since what caused them may change without the TracingConfig changing:
+	// a TracingPolicy a namespace-scoped controller does not watch, or a schedule window closing.
+	if outcome == string(errorClassTerminal) {
+		return ctrl.Result{RequeueAfter: r.requeueInterval()}, nil
 	}
-	out := new(TracingConfig)
-	tc.DeepCopyInto(out)
-	return out
+	return result, err
 }
 
-// DeepCopyInto copies all properties of this object into another object of the same type
-func (tcs *TracingConfigSpec) DeepCopyInto(out *TracingConfigSpec) {
-	*out = *tcs
-	if tcs.Selector != nil {
-		in, out := &tcs.Selector, &out.Selector
-		*out = new(metav1.LabelSelector)
-		(*in).DeepCopyInto(*out)
-	}
-	if tcs.Headers != nil {
-		in, out := &tcs.Headers, &out.Headers
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+func (r *TracingConfigReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log.Printf("Reconciling TracingConfig %s/%s", req.Namespace, req.Name)
+
+	// Fetch the TracingConfig instance
+	var tracingConfig tracingv1.TracingConfig
+	fetchCtx, fetchSpan := startSpan(ctx, "FetchTracingConfig")
+	err := r.Get(fetchCtx, req.NamespacedName, &tracingConfig)
+	endSpan(fetchSpan, client.IgnoreNotFound(err))
+	if err != nil {
+		log.Printf("Unable to fetch TracingConfig: %v", err)
+		if apierrors.IsNotFound(err) {
+			r.forgetProbe(req.NamespacedName)
+			forgetTracingConfigMetrics(req.Namespace, req.Name)
 		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	if tcs.Attributes != nil {
-		in, out := &tcs.Attributes, &out.Attributes
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+	trace.SpanFromContext(ctx).SetAttributes(tracingConfigAttributes(&tracingConfig)...)
+
+	// Clean up the rendered ConfigMaps in every namespace before letting the TracingConfig go
+	if !tracingConfig.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&tracingConfig, cleanupFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.cleanupNamespaces(ctx, &tracingConfig, nil); err != nil {
+			log.Printf("Failed to clean up TracingConfig %s/%s: %v", req.Namespace, req.Name, err)
+			recordReconcileError(err)
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(&tracingConfig, cleanupFinalizer)
+		if err := r.Update(ctx, &tracingConfig); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		r.forgetProbe(req.NamespacedName)
+		forgetTracingConfigMetrics(req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&tracingConfig, cleanupFinalizer) {
+		controllerutil.AddFinalizer(&tracingConfig, cleanupFinalizer)
+		if err := r.Update(ctx, &tracingConfig); err != nil {
+			log.Printf("Failed to add finalizer: %v", err)
+			recordReconcileError(err)
+			return ctrl.Result{}, err
 		}
 	}
-}
 
-// DeepCopyInto copies all properties of this object into another object of the same type
-func (tcs *TracingConfigStatus) DeepCopyInto(out *TracingConfigStatus) {
-	*out = *tcs
-	if tcs.AppliedAt != nil {
-		in, out := &tcs.AppliedAt, &out.AppliedAt
-		*out = (*in).DeepCopy()
+	// Restore a recorded spec; its update brings us back here
+	if tracingConfig.Spec.RollbackTo != nil {
+		return r.rollback(ctx, &tracingConfig)
 	}
-	if tcs.TargetPods != nil {
-		in, out := &tcs.TargetPods, &out.TargetPods
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+
+	// Update status to Pending
+	tracingConfig.Status.Phase = "Pending"
+	tracingConfig.Status.Message = "Processing tracing configuration"
+	if err := r.updateStatus(ctx, &tracingConfig); err != nil {
+		log.Printf("Failed to update status to Pending: %v", err)
 	}
-}
 
-// DeepCopyObject implements runtime.Object interface
-func (tcl *TracingConfigList) DeepCopyObject() runtime.Object {
-	if tcl == nil {
-		return nil
+	// Fill the fields the TracingConfig leaves unset from the namespace's TracingDefaults
+	if err := r.applyDefaults(ctx, &tracingConfig); err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Failed to apply tracing defaults", err)
 	}
-	out := new(TracingConfigList)
-	tcl.DeepCopyInto(out)
-	return out
-}
 
-// DeepCopyInto copies all properties of this object into another object of the same type
-func (tcl *TracingConfigList) DeepCopyInto(out *TracingConfigList) {
-	*out = *tcl
-	out.TypeMeta = tcl.TypeMeta
-	tcl.ListMeta.DeepCopyInto(&out.ListMeta)
-	if tcl.Items != nil {
-		in, out := &tcl.Items, &out.Items
-		*out = make([]TracingConfig, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	// Find target pods based on selector
+	selector := labels.Everything()
+	if tracingConfig.Spec.Selector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(tracingConfig.Spec.Selector)
+		if err != nil {
+			return r.failReconcile(ctx, &tracingConfig, "Invalid label selector", terminal("InvalidSelector", err))
 		}
 	}
-}
 
-// TracingConfigReconciler reconciles TracingConfig objects
-type TracingConfigReconciler struct {
-	client.Client
-	Scheme    *runtime.Scheme
-	K8sClient kubernetes.Interface
-}
+	// A templated service name is rendered per workload, make sure it parses before touching any
+	if render.IsServiceNameTemplate(tracingConfig.Spec.ServiceName) {
+		if _, err := render.ParseServiceName(tracingConfig.Spec.ServiceName); err != nil {
+			return r.failReconcile(ctx, &tracingConfig, "Invalid service name", terminal("InvalidServiceName", err))
+		}
+	}
 
-func (r *TracingConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log.Printf("Reconciling TracingConfig %s/%s", req.Namespace, req.Name)
+	// Apply the schedule window that is open right now, if any
+	active, err := render.EvaluateSchedule(tracingConfig.Spec.Schedule, time.Now())
+	if err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Invalid schedule", terminal("InvalidSchedule", err))
+	}
+	effectiveSpec := render.ApplySchedule(tracingConfig.Spec, active)
 
-	// Fetch the TracingConfig instance
-	var tracingConfig TracingConfig
-	if err := r.Get(ctx, req.NamespacedName, &tracingConfig); err != nil {
-		log.Printf("Unable to fetch TracingConfig: %v", err)
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	// Let the span budget pick the sampling rate, from the throughput the collector observed
+	if err := r.adjustBudget(ctx, &tracingConfig, active, time.Now()); err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Invalid span budget", err)
 	}
+	effectiveSpec = render.ApplyBudget(effectiveSpec, tracingConfig.Status.Budget, active)
 
-	// Update status to Pending
-	tracingConfig.Status.Phase = "Pending"
-	tracingConfig.Status.Message = "Processing tracing configuration"
-	if err := r.Status().Update(ctx, &tracingConfig); err != nil {
-		log.Printf("Failed to update status to Pending: %v", err)
+	targetNamespaces, err := r.resolveTargetNamespaces(ctx, &tracingConfig)
+	if err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Failed to resolve target namespaces", err)
 	}
+	// In namespace-scoped mode nothing can be rendered outside the watched namespaces
+	targetNamespaces, workloadFailures := r.splitWatched(targetNamespaces)
 
-	// Find target pods based on selector
-	targetNamespace := tracingConfig.Spec.Namespace
-	if targetNamespace == "" {
-		targetNamespace = tracingConfig.Namespace
+	// Keep the spec within the limits of the TracingPolicies selecting the target namespaces
+	effectiveSpec, err = r.enforcePolicies(ctx, &tracingConfig, effectiveSpec, targetNamespaces)
+	if err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Rejected by tracing policy", err)
 	}
 
-	var pods corev1.PodList
-	listOpts := []client.ListOption{
-		client.InNamespace(targetNamespace),
+	// Render one ConfigMap per target namespace. A failing namespace or workload must not keep
+	// the others from being injected, so collect the failures.
+	configMapName := render.ConfigMapName(&tracingConfig)
+	data := render.ConfigMapData(&effectiveSpec)
+	hash := render.RevisionHash(&effectiveSpec, data)
+
+	// With a rollout strategy every revision gets its own ConfigMap, so that the workloads that
+	// have not been moved yet keep running the previous one
+	rollout, err := r.planRollout(ctx, &tracingConfig, targetNamespaces, selector, hash, time.Now())
+	if err != nil {
+		return r.failReconcile(ctx, &tracingConfig, "Failed to plan rollout", err)
+	}
+	if rollout != nil {
+		configMapName = render.RevisionConfigMapName(configMapName, hash)
 	}
 
-	if tracingConfig.Spec.Selector != nil {
-		selector, err := metav1.LabelSelectorAsSelector(tracingConfig.Spec.Selector)
+	var workloads []tracingv1.WorkloadStatus
+	var configMaps []tracingv1.ConfigMapReference
+	var skippedWorkloads []tracingv1.SkippedWorkload
+	var drift []tracingv1.DriftReport
+	var transientErrs []error
+	for _, namespace := range targetNamespaces {
+		result, err := r.reconcileNamespace(ctx, &tracingConfig, namespace, selector, configMapName, data, hash, rollout)
 		if err != nil {
-			log.Printf("Invalid label selector: %v", err)
-			tracingConfig.Status.Phase = "Failed"
-			tracingConfig.Status.Message = fmt.Sprintf("Invalid label selector: %v", err)
-			r.Status().Update(ctx, &tracingConfig)
-			return ctrl.Result{}, err
+			return r.failReconcile(ctx, &tracingConfig, fmt.Sprintf("Failed to reconcile namespace %s", namespace), err)
+		}
+		workloads = append(workloads, result.Workloads...)
+		drift = append(drift, result.Drift...)
+		skippedWorkloads = append(skippedWorkloads, result.Skipped...)
+		for _, failure := range result.Failures {
+			workloadFailures = append(workloadFailures, failure.WorkloadFailure)
+			if failure.Transient {
+				transientErrs = append(transientErrs, failure.Err)
+			}
+		}
+		if len(result.Failures) == 0 || result.Failures[0].Kind != "ConfigMap" {
+			configMaps = append(configMaps, tracingv1.ConfigMapReference{Namespace: namespace, Name: configMapName})
+		}
+	}
+	failedWorkloads.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name).Set(float64(len(workloadFailures)))
+
+	// Remove the ConfigMap from namespaces that fell out of scope
+	if err := r.cleanupNamespaces(ctx, &tracingConfig, targetNamespaces); err != nil {
+		log.Printf("Failed to clean up namespaces: %v", err)
+		recordReconcileError(err)
+		transientErrs = append(transientErrs, err)
+	}
+
+	// Probe the OTLP endpoint from the controller pod while tracing is enabled, using the TLS
+	// Secrets of the first target namespace
+	if r.Probes != nil {
+		if effectiveSpec.Enabled {
+			probeNamespace := tracingConfig.Namespace
+			if len(targetNamespaces) > 0 {
+				probeNamespace = targetNamespaces[0]
+			}
+			r.Probes.track(req.NamespacedName, probeNamespace)
+		} else {
+			r.Probes.forget(req.NamespacedName)
 		}
-		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
 	}
 
-	if err := r.List(ctx, &pods, listOpts...); err != nil {
-		log.Printf("Failed to list pods: %v", err)
-		tracingConfig.Status.Phase = "Failed"
-		tracingConfig.Status.Message = fmt.Sprintf("Failed to list pods: %v", err)
-		r.Status().Update(ctx, &tracingConfig)
+	// Record the spec that was just rendered in the revision history, resolved against the
+	// TracingDefaults so that a rollback restores what was rendered even after they change
+	if revision, err := r.recordRevision(ctx, &tracingConfig, &tracingConfig.Spec); err != nil {
+		log.Printf("Failed to record revision: %v", err)
+		recordReconcileError(err)
+		transientErrs = append(transientErrs, err)
+	} else {
+		tracingConfig.Status.CurrentRevision = revision
+	}
+
+	// Update status to Applied, or Degraded if some workloads could not be injected
+	now := metav1.Now()
+	tracingConfig.Status.AppliedAt = &now
+	tracingConfig.Status.ActiveWindow = ""
+	if active.Window != nil {
+		tracingConfig.Status.ActiveWindow = active.Window.Name
+	}
+	tracingConfig.Status.NextScheduleTransition = nil
+	if !active.NextTransition.IsZero() {
+		next := metav1.NewTime(active.NextTransition)
+		tracingConfig.Status.NextScheduleTransition = &next
+	}
+	tracingConfig.Status.ConfigHash = hash
+	tracingConfig.Status.Workloads = workloads
+	tracingConfig.Status.WorkloadCount = int32(len(workloads))
+	tracingConfig.Status.ReadyPods, tracingConfig.Status.StalePods = 0, 0
+	for _, workload := range workloads {
+		tracingConfig.Status.ReadyPods += workload.ReadyPods
+		tracingConfig.Status.StalePods += workload.StalePods
+	}
+	tracingConfig.Status.TargetNamespaces = targetNamespaces
+	tracingConfig.Status.ConfigMaps = configMaps
+	tracingConfig.Status.FailedWorkloads = workloadFailures
+	tracingConfig.Status.SkippedWorkloads = skippedWorkloads
+	r.reportDrift(&tracingConfig, drift)
+	reportEnvConflicts(&tracingConfig, workloads)
+	tracingConfig.Status.Rollout = nil
+	meta.RemoveStatusCondition(&tracingConfig.Status.Conditions, conditionRolledOut)
+	if rollout != nil {
+		tracingConfig.Status.Rollout = &rollout.status
+		condition := metav1.Condition{
+			Type:               conditionRolledOut,
+			Status:             metav1.ConditionFalse,
+			Reason:             rollout.status.Phase,
+			Message:            rollout.status.Message,
+			ObservedGeneration: tracingConfig.Generation,
+		}
+		if rollout.status.Phase == rolloutPhaseComplete {
+			condition.Status = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&tracingConfig.Status.Conditions, condition)
+	}
+	if len(workloadFailures) > 0 {
+		tracingConfig.Status.Phase = "Degraded"
+		tracingConfig.Status.Message = fmt.Sprintf("Tracing configuration applied to %d workloads in %d namespaces, %d failed", len(workloads), len(targetNamespaces), len(workloadFailures))
+		meta.SetStatusCondition(&tracingConfig.Status.Conditions, metav1.Condition{
+			Type:               conditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             reasonWorkloadsFailed,
+			Message:            tracingConfig.Status.Message,
+			ObservedGeneration: tracingConfig.Generation,
+		})
+	} else {
+		tracingConfig.Status.Phase = "Applied"
+		tracingConfig.Status.Message = fmt.Sprintf("Tracing configuration applied to %d workloads in %d namespaces", len(workloads), len(targetNamespaces))
+		meta.SetStatusCondition(&tracingConfig.Status.Conditions, metav1.Condition{
+			Type:               conditionReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             reasonReconciled,
+			Message:            tracingConfig.Status.Message,
+			ObservedGeneration: tracingConfig.Generation,
+		})
+	}
+
+	if err := r.updateStatus(ctx, &tracingConfig); err != nil {
+		log.Printf("Failed to update status: %v", err)
+		recordReconcileError(err)
 		return ctrl.Result{}, err
 	}
 
-	// Create or update ConfigMap with tracing configuration
-	configMapName := fmt.Sprintf("%s-tracing-config", tracingConfig.Name)
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      configMapName,
-			Namespace: targetNamespace,
-		},
-		Data: map[string]string{
-			"OTEL_EXPORTER_OTLP_ENDPOINT": tracingConfig.Spec.Endpoint,
-			"OTEL_SERVICE_NAME":           tracingConfig.Spec.ServiceName,
-			"OTEL_TRACES_SAMPLER":         "traceidratio",
-			"OTEL_TRACES_SAMPLER_ARG":     fmt.Sprintf("%.2f", tracingConfig.Spec.SamplingRate),
+	// Workloads that failed for transient reasons are retried with backoff
+	if len(transientErrs) > 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(transientErrs)
+	}
+
+	// Come back right at the next window boundary to rewrite the configuration
+	requeueAfter := r.requeueInterval()
+	if !active.NextTransition.IsZero() {
+		if untilTransition := time.Until(active.NextTransition); untilTransition < requeueAfter {
+			requeueAfter = untilTransition + time.Second
+		}
+	}
+	// Keep an unfinished rollout moving
+	if rollout != nil && rollout.requeueAfter > 0 && rollout.requeueAfter < requeueAfter {
+		requeueAfter = rollout.requeueAfter
+	}
+	// Check the span budget as often as it may be adjusted
+	if budget := tracingConfig.Spec.Budget; budget != nil && r.Throughput != nil {
+		if interval, err := render.BudgetInterval(budget); err == nil && interval < requeueAfter {
+			requeueAfter = interval
+		}
+	}
+
+	log.Printf("Successfully reconciled TracingConfig %s/%s", req.Namespace, req.Name)
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// namespaceResult is what reconciling one target namespace produced
+type namespaceResult struct {
+	Workloads []tracingv1.WorkloadStatus
+	// Failures starts with the ConfigMap failure, if any
+	Failures []namespaceFailure
+	Drift    []tracingv1.DriftReport
+	Skipped  []tracingv1.SkippedWorkload
+}
+
+// namespaceFailure is a failure recorded in status together with the error that caused it
+type namespaceFailure struct {
+	tracingv1.WorkloadFailure
+	Err       error
+	Transient bool
+}
+
+func newNamespaceFailure(kind, namespace, name string, err error) namespaceFailure {
+	recordReconcileError(err)
+	return namespaceFailure{
+		WorkloadFailure: tracingv1.WorkloadFailure{
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Reason:    errorReason(err),
+			Message:   err.Error(),
 		},
+		Err:       err,
+		Transient: classifyError(err) == errorClassTransient,
+	}
+}
+
+// reconcileNamespace renders the ConfigMap into one target namespace and injects it into the
+// selected deployments there, as far as the rollout allows. The returned error is reserved for
+// failures that should abort the whole reconcile.
+func (r *TracingConfigReconciler) reconcileNamespace(ctx context.Context, tracingConfig *tracingv1.TracingConfig, namespace string, selector labels.Selector, configMapName string, data map[string]string, hash string, rollout *rolloutPlan) (*namespaceResult, error) {
+	ctx, span := startSpan(ctx, "ReconcileNamespace", semconv.K8SNamespaceNameKey.String(namespace))
+	defer span.End()
+
+	result := &namespaceResult{}
+
+	// Make sure the certificates we are about to mount actually exist
+	if err := r.validateTLSSecrets(ctx, namespace, tracingConfig.Spec.TLS); err != nil {
+		log.Printf("Invalid TLS configuration in namespace %s: %v", namespace, err)
+		result.Failures = append(result.Failures, newNamespaceFailure("ConfigMap", namespace, configMapName, err))
+		return result, nil
+	}
+
+	// Create or update ConfigMap with tracing configuration
+	renderedLabels := configMapLabels(tracingConfig)
+	if rollout != nil {
+		renderedLabels[labelRevision] = hash
+	}
+	diff, err := r.syncConfigMap(ctx, namespace, configMapName, renderedLabels, data, tracingConfig.Spec.EnforcesDrift())
+	if err != nil {
+		log.Printf("Failed to sync ConfigMap in namespace %s: %v", namespace, err)
+		configMapSyncFailures.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name).Inc()
+		result.Failures = append(result.Failures, newNamespaceFailure("ConfigMap", namespace, configMapName, err))
+		return result, nil
+	}
+	if len(diff) > 0 {
+		result.Drift = append(result.Drift, tracingv1.DriftReport{Kind: "ConfigMap", Namespace: namespace, Name: configMapName, Diff: diff})
 	}
 
-	// Add optional configurations
-	if tracingConfig.Spec.ExportTimeout != "" {
-		configMap.Data["OTEL_EXPORTER_OTLP_TIMEOUT"] = tracingConfig.Spec.ExportTimeout
+	// Headers are kept out of the ConfigMap, in a Secret of their own
+	secretName := render.SecretName(tracingConfig)
+	headers, secretContents, err := r.readSourceSecrets(ctx, namespace, &tracingConfig.Spec)
+	if err != nil {
+		log.Printf("Failed to read source Secrets in namespace %s: %v", namespace, err)
+		result.Failures = append(result.Failures, newNamespaceFailure("Secret", namespace, secretName, err))
+		return result, nil
 	}
-	if tracingConfig.Spec.BatchTimeout != "" {
-		configMap.Data["OTEL_BSP_SCHEDULE_DELAY"] = tracingConfig.Spec.BatchTimeout
+	secretData := render.SecretData(&tracingConfig.Spec, headers)
+	diff, err = r.syncSecret(ctx, namespace, secretName, configMapLabels(tracingConfig), secretData, tracingConfig.Spec.EnforcesDrift())
+	if err != nil {
+		log.Printf("Failed to sync Secret in namespace %s: %v", namespace, err)
+		result.Failures = append(result.Failures, newNamespaceFailure("Secret", namespace, secretName, err))
+		return result, nil
 	}
-	if tracingConfig.Spec.MaxBatchSize > 0 {
-		configMap.Data["OTEL_BSP_MAX_EXPORT_BATCH_SIZE"] = fmt.Sprintf("%d", tracingConfig.Spec.MaxBatchSize)
+	if len(diff) > 0 {
+		result.Drift = append(result.Drift, tracingv1.DriftReport{Kind: "Secret", Namespace: namespace, Name: secretName, Diff: diff})
 	}
 
-	// Add custom attributes
-	for key, value := range tracingConfig.Spec.Attributes {
-		configMap.Data[fmt.Sprintf("OTEL_RESOURCE_ATTRIBUTES_%s", key)] = value
+	// Workloads see where the sensitive keys come from, and roll when a Secret they read changes
+	var secretKeys []string
+	for key, value := range secretData {
+		secretKeys = append(secretKeys, key)
+		secretContents[key] = value
+	}
+	if len(secretKeys) == 0 {
+		secretName = ""
+	}
+	data = render.WithSecretKeys(data, secretName, secretKeys)
+	secretDigest := ""
+	if len(secretContents) > 0 {
+		secretDigest = render.ConfigHash(secretContents)
 	}
 
-	// Create or update the ConfigMap
-	existingConfigMap := &corev1.ConfigMap{}
-	err := r.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: targetNamespace}, existingConfigMap)
+	// Update deployments to use the tracing configuration
+	deployments, skipped, err := r.selectDeployments(ctx, tracingConfig, namespace, selector)
 	if err != nil {
-		if client.IgnoreNotFound(err) == nil {
-			// ConfigMap doesn't exist, create it
-			if err := r.Create(ctx, configMap); err != nil {
-				log.Printf("Failed to create ConfigMap: %v", err)
-				tracingConfig.Status.Phase = "Failed"
-				tracingConfig.Status.Message = fmt.Sprintf("Failed to create ConfigMap: %v", err)
-				r.Status().Update(ctx, &tracingConfig)
-				return ctrl.Result{}, err
+		return nil, err
+	}
+	result.Skipped = skipped
+	drift, failures := r.releaseSkipped(ctx, tracingConfig, skipped)
+	result.Drift = append(result.Drift, drift...)
+	result.Failures = append(result.Failures, failures...)
+	span.SetAttributes(attribute.Int("k8s.deployment.count", len(deployments)))
+
+	// Debug sessions are layered on top of the rendered configuration per workload
+	debugSessions, err := r.activeDebugSessions(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range deployments {
+		deployment := &deployments[i]
+		override := render.DebugOverrideFor(debugSessions, deployment)
+		// Debug sessions stay within the cap of the TracingPolicies as well
+		if limit := tracingConfig.Status.MaxSamplingRate; override != nil && limit != nil && override.SamplingRate > *limit {
+			override.SamplingRate = *limit
+		}
+
+		// A templated service name is set on the workload itself rather than in the shared ConfigMap
+		serviceName := ""
+		if render.IsServiceNameTemplate(tracingConfig.Spec.ServiceName) {
+			if serviceName, err = render.ServiceName(tracingConfig.Spec.ServiceName, deployment); err != nil {
+				log.Printf("Failed to render service name for deployment %s/%s: %v", namespace, deployment.Name, err)
+				result.Failures = append(result.Failures, newNamespaceFailure("Deployment", namespace, deployment.Name, terminal("InvalidServiceName", err)))
+				continue
 			}
-			log.Printf("Created ConfigMap %s/%s", targetNamespace, configMapName)
-		} else {
-			log.Printf("Failed to get ConfigMap: %v", err)
-			return ctrl.Result{}, err
 		}
-	} else {
-		// ConfigMap exists, update it
-		existingConfigMap.Data = configMap.Data
-		if err := r.Update(ctx, existingConfigMap); err != nil {
-			log.Printf("Failed to update ConfigMap: %v", err)
-			tracingConfig.Status.Phase = "Failed"
-			tracingConfig.Status.Message = fmt.Sprintf("Failed to update ConfigMap: %v", err)
-			r.Status().Update(ctx, &tracingConfig)
-			return ctrl.Result{}, err
+
+		workloadData := render.WithDebugOverride(data, override)
+		if serviceName != "" {
+			workloadData = render.WithServiceName(workloadData, serviceName)
+		}
+		workloadHash := hash
+		if override != nil || serviceName != "" || secretDigest != "" {
+			workloadHash = render.RevisionHash(&tracingConfig.Spec, render.WithSecretDigest(workloadData, secretDigest))
+		}
+
+		// Workloads outside the current batch keep their configuration and show up as stale
+		var envConflicts []tracingv1.EnvConflict
+		if rollout.admit(deployment) {
+			diff, conflicts, err := r.injectDeployment(ctx, tracingConfig, deployment, configMapName, secretName, hash, workloadHash, workloadData, override, serviceName)
+			if err != nil {
+				log.Printf("Failed to update deployment %s/%s: %v", namespace, deployment.Name, err)
+				result.Failures = append(result.Failures, newNamespaceFailure("Deployment", namespace, deployment.Name, err))
+				continue
+			}
+			if len(diff) > 0 {
+				result.Drift = append(result.Drift, tracingv1.DriftReport{Kind: "Deployment", Namespace: namespace, Name: deployment.Name, Diff: diff})
+			}
+			envConflicts = conflicts
+		}
+
+		workload, err := r.workloadStatus(ctx, deployment, render.ConfigMapName(tracingConfig), workloadHash)
+		if err != nil {
+			return nil, err
 		}
-		log.Printf("Updated ConfigMap %s/%s", targetNamespace, configMapName)
+		if override != nil {
+			workload.DebugSession = override.Session
+		}
+		workload.ServiceName = tracingConfig.Spec.ServiceName
+		if serviceName != "" {
+			workload.ServiceName = serviceName
+		}
+		workload.EnvConflicts = envConflicts
+		result.Workloads = append(result.Workloads, workload)
 	}
 
-	// Update deployments to use the tracing configuration
-	var deployments appsv1.DeploymentList
-	if err := r.List(ctx, &deployments, listOpts...); err != nil {
-		log.Printf("Failed to list deployments: %v", err)
-	} else {
-		for _, deployment := range deployments.Items {
-			updated := false
-			for i := range deployment.Spec.Template.Spec.Containers {
-				container := &deployment.Spec.Template.Spec.Containers[i]
-
-				// Add environment variables from ConfigMap
-				envFromExists := false
-				for _, envFrom := range container.EnvFrom {
-					if envFrom.ConfigMapRef != nil && envFrom.ConfigMapRef.Name == configMapName {
-						envFromExists = true
-						break
-					}
-				}
+	// Drop the revisions that no workload runs anymore
+	current := ""
+	if rollout != nil {
+		current = configMapName
+	}
+	if err := r.pruneRevisions(ctx, tracingConfig, namespace, current); err != nil {
+		log.Printf("Failed to prune ConfigMap revisions in namespace %s: %v", namespace, err)
+		result.Failures = append(result.Failures, newNamespaceFailure("ConfigMap", namespace, configMapName, err))
+	}
+	return result, nil
+}
 
-				if !envFromExists {
-					container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
-						ConfigMapRef: &corev1.ConfigMapEnvSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: configMapName,
-							},
-						},
-					})
-					updated = true
-				}
+// failReconcile records err in the status and returns it; Reconcile decides from its class
+// whether the request is retried
+func (r *TracingConfigReconciler) failReconcile(ctx context.Context, tracingConfig *tracingv1.TracingConfig, message string, err error) (ctrl.Result, error) {
+	class, reason := classifyError(err), errorReason(err)
+	log.Printf("%s (%s): %v", message, class, err)
+	recordReconcileError(err)
+
+	tracingConfig.Status.Phase = "Failed"
+	tracingConfig.Status.Message = fmt.Sprintf("%s: %v", message, err)
+	meta.SetStatusCondition(&tracingConfig.Status.Conditions, metav1.Condition{
+		Type:               conditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            tracingConfig.Status.Message,
+		ObservedGeneration: tracingConfig.Generation,
+	})
+	if statusErr := r.updateStatus(ctx, tracingConfig); statusErr != nil {
+		log.Printf("Failed to update status: %v", statusErr)
+	}
+	return ctrl.Result{}, err
+}
+
+// syncConfigMap creates the rendered ConfigMap or brings an existing one up to date. The hash of
+// the data we write is recorded on the ConfigMap; if the live data no longer matches it, someone
+// edited the ConfigMap and the returned diff describes how. Unless enforce is set, such a
+// ConfigMap is left alone.
+func (r *TracingConfigReconciler) syncConfigMap(ctx context.Context, namespace, name string, configMapLabels, data map[string]string, enforce bool) (drift []string, err error) {
+	ctx, span := startSpan(ctx, "SyncConfigMap",
+		semconv.K8SNamespaceNameKey.String(namespace),
+		attribute.String("k8s.configmap.name", name),
+	)
+	defer func() { endSpan(span, err) }()
+
+	existingConfigMap := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, existingConfigMap)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+
+		// ConfigMap doesn't exist, create it
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Labels:      configMapLabels,
+				Annotations: map[string]string{tracingv1.AnnotationConfigHash: render.ConfigHash(data)},
+			},
+			Data: data,
+		}
+		if err := r.Create(ctx, configMap); err != nil {
+			return nil, fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		span.SetAttributes(attribute.String("k8s.operation", "create"))
+		log.Printf("Created ConfigMap %s/%s", namespace, name)
+		return nil, nil
+	}
+
+	// Compare against what we wrote last time; ConfigMaps from before the hash was recorded are trusted.
+	// Hand edits are only left in place while the spec still renders what was written: a spec
+	// change is applied over them.
+	if written := existingConfigMap.Annotations[tracingv1.AnnotationConfigHash]; written != "" && written != render.ConfigHash(existingConfigMap.Data) {
+		span.SetAttributes(attribute.Bool("drift.detected", true))
+		if written != render.ConfigHash(data) && !enforce {
+			log.Printf("Replacing hand edits to ConfigMap %s/%s with the changed spec", namespace, name)
+		} else {
+			drift = diffConfigMapData(existingConfigMap.Data, data)
+			if !enforce {
+				return drift, nil
 			}
+		}
+	}
 
-			if updated {
-				if err := r.Update(ctx, &deployment); err != nil {
-					log.Printf("Failed to update deployment %s: %v", deployment.Name, err)
-				} else {
-					log.Printf("Updated deployment %s with tracing configuration", deployment.Name)
+	// ConfigMap exists, update it
+	if existingConfigMap.Labels == nil {
+		existingConfigMap.Labels = map[string]string{}
+	}
+	for key, value := range configMapLabels {
+		existingConfigMap.Labels[key] = value
+	}
+	if existingConfigMap.Annotations == nil {
+		existingConfigMap.Annotations = map[string]string{}
+	}
+	existingConfigMap.Annotations[tracingv1.AnnotationConfigHash] = render.ConfigHash(data)
+	existingConfigMap.Data = data
+	if err := r.Update(ctx, existingConfigMap); err != nil {
+		return drift, fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	span.SetAttributes(attribute.String("k8s.operation", "update"))
+	log.Printf("Updated ConfigMap %s/%s", namespace, name)
+	return drift, nil
+}
+
+// injectDeployment points every container of the deployment at the rendered ConfigMap and
+// Secret, mounts the TLS material, sets the per-workload overrides and stamps the revision and config
+// hash, updating the deployment only if something changed. When the deployment already carries the current config hash, any
+// change it needs is drift: it is described in the returned diff and, unless the TracingConfig
+// enforces drift, left in place. OTEL_* env vars the containers set themselves shadow data, the
+// workload's rendered values; they are resolved by the spec's conflict policy and returned.
+func (r *TracingConfigReconciler) injectDeployment(ctx context.Context, tracingConfig *tracingv1.TracingConfig, deployment *appsv1.Deployment, configMapName, secretName, revision, hash string, data map[string]string, override *render.DebugOverride, serviceName string) (drift []string, conflicts []tracingv1.EnvConflict, err error) {
+	ctx, span := startSpan(ctx, "InjectWorkload",
+		semconv.K8SNamespaceNameKey.String(deployment.Namespace),
+		semconv.K8SDeploymentNameKey.String(deployment.Name),
+	)
+	defer func() { endSpan(span, err) }()
+
+	spec := &tracingConfig.Spec
+	baseConfigMapName := render.ConfigMapName(tracingConfig)
+	inSync := deployment.Spec.Template.Annotations[tracingv1.AnnotationConfigHash] == hash
+	desired := deployment.DeepCopy()
+
+	// Sidecars and containers left out by the spec get nothing, and lose what they got before
+	targets := func(container string) bool { return render.TargetsContainer(spec, container) }
+	targeted := 0
+	for _, container := range desired.Spec.Template.Spec.Containers {
+		if targets(container.Name) {
+			targeted++
+		}
+	}
+	if targeted == 0 {
+		return nil, nil, terminal("NoTargetContainers", fmt.Errorf("none of the containers of deployment %s is targeted by the TracingConfig", deployment.Name))
+	}
+
+	var changes []string
+	updated := false
+
+	// Explicit env beats envFrom, so OTEL_* vars the containers set themselves shadow ours
+	workload := render.WorkloadReference{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name}
+	found := findEnvConflicts(&desired.Spec.Template, spec, workload, data, targets)
+	var respected []envConflict
+	switch {
+	case len(found) > 0 && spec.EnvConflictPolicy == tracingv1.EnvConflictFail:
+		return nil, nil, terminal("EnvConflict", fmt.Errorf("deployment %s already sets %s", deployment.Name, describeEnvConflicts(found)))
+	case spec.RespectsEnv():
+		respected = found
+		conflicts = envConflictStatus(found, tracingv1.EnvConflictRespected)
+	default:
+		for _, conflict := range found {
+			for i := range desired.Spec.Template.Spec.Containers {
+				if container := &desired.Spec.Template.Spec.Containers[i]; container.Name == conflict.container {
+					removeEnv(container, conflict.env.Name)
 				}
 			}
+			changes = append(changes, fmt.Sprintf("container %s: env %s overrides the rendered configuration", conflict.container, conflict.env.Name))
+			updated = true
 		}
+		conflicts = envConflictStatus(found, tracingv1.EnvConflictOverridden)
 	}
 
-	// Collect target pod names
-	var targetPodNames []string
-	for _, pod := range pods.Items {
-		targetPodNames = append(targetPodNames, pod.Name)
+	// Respected vars are set aside while we set ours, and ours are not set where one is respected
+	keep := map[string]bool{}
+	for _, conflict := range respected {
+		keep[conflict.key()] = true
+	}
+	stashed := stashEnv(&desired.Spec.Template, keep)
+	targetsFor := func(name string) func(container string) bool {
+		return func(container string) bool { return targets(container) && !keep[container+"/"+name] }
 	}
 
-	// Update status to Applied
-	now := metav1.Now()
-	tracingConfig.Status.Phase = "Applied"
-	tracingConfig.Status.Message = fmt.Sprintf("Tracing configuration applied to %d pods", len(targetPodNames))
-	tracingConfig.Status.AppliedAt = &now
-	tracingConfig.Status.TargetPods = targetPodNames
+	for i := range desired.Spec.Template.Spec.Containers {
+		container := &desired.Spec.Template.Spec.Containers[i]
+		if !targets(container.Name) {
+			if removeEnvFrom(container, func(name string) bool { return render.IsTracingConfigMap(name, baseConfigMapName) }) {
+				changes = append(changes, fmt.Sprintf("container %s: envFrom ConfigMap %s not expected", container.Name, baseConfigMapName))
+				updated = true
+			}
+			continue
+		}
 
-	if err := r.Status().Update(ctx, &tracingConfig); err != nil {
-		log.Printf("Failed to update status: %v", err)
-		return ctrl.Result{}, err
+		// Add environment variables from ConfigMap, replacing a reference to another revision
+		envFromExists, otherRevision := false, false
+		for j := 0; j < len(container.EnvFrom); j++ {
+			ref := container.EnvFrom[j].ConfigMapRef
+			if ref == nil || !render.IsTracingConfigMap(ref.Name, baseConfigMapName) {
+				continue
+			}
+			if ref.Name == configMapName && !envFromExists {
+				envFromExists = true
+				continue
+			}
+			container.EnvFrom = append(container.EnvFrom[:j], container.EnvFrom[j+1:]...)
+			j--
+			otherRevision = true
+			updated = true
+		}
+
+		// Optional, so that pods still start while the ConfigMap is being cleaned up
+		if !envFromExists {
+			optional := true
+			container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: configMapName,
+					},
+					Optional: &optional,
+				},
+			})
+			if !otherRevision {
+				changes = append(changes, fmt.Sprintf("container %s: envFrom ConfigMap %s missing", container.Name, configMapName))
+			}
+			updated = true
+		}
 	}
 
-	log.Printf("Successfully reconciled TracingConfig %s/%s", req.Namespace, req.Name)
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	// Read the headers from the rendered Secret
+	if ensureSecretEnv(&desired.Spec.Template, secretName, targets) {
+		changes = append(changes, "envFrom Secret modified")
+		updated = true
+	}
+
+	// Mount or unmount the CA bundle and client certificate
+	if ensureTLSVolumes(&desired.Spec.Template.Spec, spec.TLS, targets) {
+		changes = append(changes, "TLS volumes or mounts modified")
+		updated = true
+	}
+
+	// Apply or revert a debug session override
+	if ensureDebugOverride(&desired.Spec.Template, override, targetsFor("OTEL_TRACES_SAMPLER_ARG")) {
+		changes = append(changes, "debug session override modified")
+		updated = true
+	}
+
+	// Set or remove the service name rendered from a serviceName template
+	if ensureServiceName(&desired.Spec.Template, serviceName, targetsFor("OTEL_SERVICE_NAME")) {
+		changes = append(changes, "service name env modified")
+		updated = true
+	}
+
+	// Expose the pod's identity and the spec's attributes as OTEL_RESOURCE_ATTRIBUTES
+	if ensureResourceAttributes(&desired.Spec.Template, spec, workload, targetsFor("OTEL_RESOURCE_ATTRIBUTES")) {
+		changes = append(changes, "resource attribute env modified")
+		updated = true
+	}
+
+	restoreEnv(&desired.Spec.Template, stashed)
+	if setRespectedEnv(&desired.Spec.Template, respected) {
+		updated = true
+	}
+
+	// Roll the pods when the rendered configuration changes
+	for key, value := range map[string]string{tracingv1.AnnotationConfigHash: hash, annotationRevision: revision} {
+		if desired.Spec.Template.Annotations[key] == value {
+			continue
+		}
+		if desired.Spec.Template.Annotations == nil {
+			desired.Spec.Template.Annotations = map[string]string{}
+		}
+		desired.Spec.Template.Annotations[key] = value
+		updated = true
+	}
+
+	if inSync && len(changes) > 0 {
+		drift = changes
+		span.SetAttributes(attribute.Bool("drift.detected", true))
+		if !spec.EnforcesDrift() {
+			return drift, conflicts, nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("workload.updated", updated))
+	if !updated {
+		return drift, conflicts, nil
+	}
+
+	if err := r.Update(ctx, desired); err != nil {
+		return drift, conflicts, err
+	}
+	*deployment = *desired
+	log.Printf("Updated deployment %s with tracing configuration", deployment.Name)
+	return drift, conflicts, nil
+}
+
+// updateStatus writes the status subresource
+func (r *TracingConfigReconciler) updateStatus(ctx context.Context, tracingConfig *tracingv1.TracingConfig) (err error) {
+	ctx, span := startSpan(ctx, "UpdateStatus", attribute.String("tracingconfig.phase", tracingConfig.Status.Phase))
+	defer func() { endSpan(span, err) }()
+
+	return r.Status().Update(ctx, tracingConfig)
+}
+
+// requeueInterval re-runs the reconcile at least every five minutes
+func (r *TracingConfigReconciler) requeueInterval() time.Duration {
+	return time.Minute * 5
+}
+
+// forgetProbe stops probing the endpoint of a TracingConfig that is gone
+func (r *TracingConfigReconciler) forgetProbe(key types.NamespacedName) {
+	if r.Probes != nil {
+		r.Probes.forget(key)
+	}
 }
 
 func (r *TracingConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&TracingConfig{}).
+	controller := ctrl.NewControllerManagedBy(mgr).
+		// Status updates would otherwise requeue the TracingConfig that wrote them
+		For(&tracingv1.TracingConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+	// Namespaces and TracingPolicies are cluster-scoped, a namespace-scoped controller cannot
+	// watch them and picks up policy changes on the periodic requeue instead
+	if !r.namespaced() {
+		controller = controller.
+			Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForNamespace)).
+			Watches(&source.Kind{Type: &tracingv1.TracingPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForPolicy))
+	}
+	// Rotated Secrets that users reference reach the pods right away
+	if r.SourceSecrets != nil {
+		controller = controller.
+			Watches(source.NewKindWithCache(secretMetadata(), r.SourceSecrets), handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForSourceSecret))
+	}
+	return controller.
+		Watches(&source.Kind{Type: &tracingv1.TracingDebugSession{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForDebugSession)).
+		Watches(&source.Kind{Type: &tracingv1.TracingDefaults{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForDefaults)).
+		// Notice hand edits to rendered ConfigMaps and injected workloads without waiting for the requeue
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForConfigMap)).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForSecret)).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, handler.EnqueueRequestsFromMapFunc(r.tracingConfigsForDeployment),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
 		Complete(r)
 }
 
@@ -339,6 +871,11 @@ func main() {
 		log.Fatalf("Failed to get in-cluster config: %v", err)
 	}
 
+	// Initialize self-tracing and trace calls to the API server
+	shutdownTracer := initTracer(context.Background())
+	defer shutdownTracer()
+	instrumentRESTConfig(config)
+
 	// Create Kubernetes client
 	k8sClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -355,29 +892,115 @@ func main() {
 	}
 
 	// Add our custom resource to the scheme
-	gv := schema.GroupVersion{Group: "observability.kubevishwa.io", Version: "v1"}
-	scheme.AddKnownTypes(gv, &TracingConfig{}, &TracingConfigList{})
-	metav1.AddToGroupVersion(scheme, gv)
+	if err := tracingv1.AddToScheme(scheme); err != nil {
+		log.Fatalf("Failed to add tracing types to scheme: %v", err)
+	}
 
-	// Create manager
-	mgr, err := ctrl.NewManager(config, ctrl.Options{
+	// Only cache the Secrets we render; the ones users reference are read through the API reader.
+	// Restrict the cache to WATCH_NAMESPACES, if set, so that namespaced Roles are enough.
+	watchNamespaces := parseWatchNamespaces(os.Getenv("WATCH_NAMESPACES"))
+	options := ctrl.Options{
 		Scheme: scheme,
-	})
+		NewCache: func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+			opts.SelectorsByObject = cache.SelectorsByObject{
+				&corev1.Secret{}: {Label: labels.SelectorFromSet(labels.Set{labelManagedBy: managedByValue})},
+			}
+			if len(watchNamespaces) > 0 {
+				return cache.MultiNamespacedCacheBuilder(watchNamespaces)(config, opts)
+			}
+			return cache.New(config, opts)
+		},
+	}
+	if len(watchNamespaces) > 0 {
+		log.Printf("Watching namespaces %v", watchNamespaces)
+	}
+
+	// Create manager
+	mgr, err := ctrl.NewManager(config, options)
 	if err != nil {
 		log.Fatalf("Failed to create manager: %v", err)
 	}
 
+	// The Secrets users reference are watched by their metadata only, in a cache of their own
+	// that the label selector above does not apply to
+	newSourceSecrets := cache.New
+	if len(watchNamespaces) > 0 {
+		newSourceSecrets = cache.MultiNamespacedCacheBuilder(watchNamespaces)
+	}
+	sourceSecrets, err := newSourceSecrets(config, cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		log.Fatalf("Failed to create Secret metadata cache: %v", err)
+	}
+	if err := mgr.Add(sourceSecrets); err != nil {
+		log.Fatalf("Failed to add Secret metadata cache: %v", err)
+	}
+
+	// Get endpoint probe interval from environment variable
+	probeInterval := time.Minute
+	if probeIntervalStr := os.Getenv("ENDPOINT_PROBE_INTERVAL"); probeIntervalStr != "" {
+		if interval, err := time.ParseDuration(probeIntervalStr); err == nil {
+			probeInterval = interval
+		}
+	}
+
 	// Setup reconciler
 	reconciler := &TracingConfigReconciler{
 		Client:    mgr.GetClient(),
 		Scheme:    mgr.GetScheme(),
 		K8sClient: k8sClient,
+		Recorder:  mgr.GetEventRecorderFor("tracing-controller"),
+
+		WatchNamespaces: watchNamespaces,
+		APIReader:       mgr.GetAPIReader(),
+		SourceSecrets:   sourceSecrets,
+	}
+	// Probe the endpoints apart from the reconcile, which keeps its own interval
+	if probeInterval > 0 {
+		reconciler.Probes = &EndpointProbes{
+			Reconciler: reconciler,
+			Prober:     &EndpointProber{Timeout: defaultProbeTimeout},
+			Interval:   probeInterval,
+		}
+		if err := mgr.Add(reconciler.Probes); err != nil {
+			log.Fatalf("Failed to add endpoint probes: %v", err)
+		}
+	}
+	// Span budgets read the collector's per-service span counter, e.g. from the spanmetrics connector
+	if metricsURL := os.Getenv("SPAN_METRICS_URL"); metricsURL != "" {
+		throughput := &collectorMetrics{
+			URL:          metricsURL,
+			Metric:       defaultSpanMetric,
+			ServiceLabel: defaultSpanServiceLabel,
+			Client:       &http.Client{Timeout: 10 * time.Second},
+		}
+		if metric := os.Getenv("SPAN_METRIC_NAME"); metric != "" {
+			throughput.Metric = metric
+		}
+		if label := os.Getenv("SPAN_METRIC_SERVICE_LABEL"); label != "" {
+			throughput.ServiceLabel = label
+		}
+		reconciler.Throughput = throughput
 	}
 
 	if err := reconciler.SetupWithManager(mgr); err != nil {
 		log.Fatalf("Failed to setup controller: %v", err)
 	}
 
+	// Check TracingConfigs against the TracingPolicies before they are stored
+	if os.Getenv("ENABLE_WEBHOOK") == "true" {
+		if err := setupWebhook(mgr, reconciler); err != nil {
+			log.Fatalf("Failed to setup webhook: %v", err)
+		}
+	}
+
+	// Expose the managed state read from the manager's cache
+	metrics.Registry.MustRegister(&tracingConfigCollector{reader: mgr.GetClient()})
+
+	debugSessionReconciler := &TracingDebugSessionReconciler{Client: mgr.GetClient()}
+	if err := debugSessionReconciler.SetupWithManager(mgr); err != nil {
+		log.Fatalf("Failed to setup debug session controller: %v", err)
+	}
+
 	log.Println("Starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		log.Fatalf("Failed to start manager: %v", err)
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// reconcileOutcomeSuccess labels reconciles that returned no error; failed ones are labelled with their error class
+const reconcileOutcomeSuccess = "Success"
+
+var (
+	endpointReachable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tracing_controller_endpoint_reachable",
+			Help: "Whether the OTLP endpoint of a TracingConfig accepted the last probe (1) or not (0)",
+		},
+		[]string{"namespace", "tracingconfig"},
+	)
+
+	endpointProbeLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tracing_controller_endpoint_probe_latency_seconds",
+			Help: "Round-trip time of the last successful OTLP endpoint probe",
+		},
+		[]string{"namespace", "tracingconfig"},
+	)
+
+	reconcileErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracing_controller_reconcile_errors_total",
+			Help: "Failed reconciles by error class (Terminal or Transient) and reason",
+		},
+		[]string{"class", "reason"},
+	)
+
+	reconcileRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracing_controller_reconcile_retries_total",
+			Help: "Reconciles requeued with backoff because of a transient error, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	failedWorkloads = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tracing_controller_failed_workloads",
+			Help: "Workloads of a TracingConfig that could not be injected in the last reconcile",
+		},
+		[]string{"namespace", "tracingconfig"},
+	)
+
+	configMapSyncFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracing_controller_configmap_sync_failures_total",
+			Help: "Failures to render a TracingConfig's ConfigMap into a target namespace",
+		},
+		[]string{"namespace", "tracingconfig"},
+	)
+
+	driftEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tracing_controller_drift_events_total",
+			Help: "Resources found changed by hand since they were last rendered, by kind",
+		},
+		[]string{"namespace", "tracingconfig", "kind"},
+	)
+
+	reconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tracing_controller_reconcile_duration_seconds",
+			Help:    "Duration of TracingConfig reconciles by outcome (Success, Terminal or Transient)",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"outcome"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		endpointReachable,
+		endpointProbeLatency,
+		reconcileErrors,
+		reconcileRetries,
+		failedWorkloads,
+		configMapSyncFailures,
+		driftEvents,
+		reconcileDuration,
+	)
+}
+
+var (
+	tracingConfigsDesc = prometheus.NewDesc(
+		"tracing_controller_tracingconfigs",
+		"TracingConfigs by status phase",
+		[]string{"phase"}, nil,
+	)
+	injectedWorkloadsDesc = prometheus.NewDesc(
+		"tracing_controller_injected_workloads",
+		"Workloads currently injected by a TracingConfig",
+		[]string{"namespace", "tracingconfig"}, nil,
+	)
+	effectiveSamplingRateDesc = prometheus.NewDesc(
+		"tracing_controller_effective_sampling_rate",
+		"Sampling rate rendered for a service right now, including schedule windows and policy caps; 0 when tracing is disabled",
+		[]string{"namespace", "tracingconfig", "service"}, nil,
+	)
+)
+
+// tracingConfigCollector reports the managed state from the cached TracingConfigs at scrape
+// time, so that deleted TracingConfigs and renamed services never leave stale series behind
+type tracingConfigCollector struct {
+	reader client.Reader
+}
+
+func (c *tracingConfigCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tracingConfigsDesc
+	ch <- injectedWorkloadsDesc
+	ch <- effectiveSamplingRateDesc
+}
+
+func (c *tracingConfigCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := c.reader.List(ctx, &tracingConfigs); err != nil {
+		log.Printf("Failed to list TracingConfigs for metrics: %v", err)
+		return
+	}
+
+	phases := map[string]int{"Pending": 0, "Applied": 0, "Degraded": 0, "Failed": 0}
+	now := time.Now()
+	for i := range tracingConfigs.Items {
+		tracingConfig := &tracingConfigs.Items[i]
+
+		phase := tracingConfig.Status.Phase
+		if phase == "" {
+			phase = "Pending"
+		}
+		phases[phase]++
+
+		injected := 0
+		for _, workload := range tracingConfig.Status.Workloads {
+			if len(workload.InjectedContainers) > 0 {
+				injected++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(injectedWorkloadsDesc, prometheus.GaugeValue, float64(injected),
+			tracingConfig.Namespace, tracingConfig.Name)
+
+		spec := tracingConfig.Spec
+		if active, err := render.EvaluateSchedule(spec.Schedule, now); err == nil {
+			spec = render.ApplyBudget(render.ApplySchedule(spec, active), tracingConfig.Status.Budget, active)
+		}
+		rate := spec.SamplingRate
+		if limit := tracingConfig.Status.MaxSamplingRate; limit != nil && rate > *limit {
+			rate = *limit
+		}
+		if !spec.Enabled {
+			rate = 0
+		}
+
+		for _, service := range reportedServices(tracingConfig) {
+			ch <- prometheus.MustNewConstMetric(effectiveSamplingRateDesc, prometheus.GaugeValue, rate,
+				tracingConfig.Namespace, tracingConfig.Name, service)
+		}
+	}
+
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(tracingConfigsDesc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
+
+// forgetTracingConfigMetrics drops every series that belongs to a deleted TracingConfig
+func forgetTracingConfigMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "tracingconfig": name}
+	endpointReachable.Delete(labels)
+	endpointProbeLatency.Delete(labels)
+	failedWorkloads.Delete(labels)
+	configMapSyncFailures.Delete(labels)
+	for _, kind := range []string{"ConfigMap", "Secret", "Deployment"} {
+		driftEvents.Delete(prometheus.Labels{"namespace": namespace, "tracingconfig": name, "kind": kind})
+	}
+}
+
+// recordReconcileError counts a failed reconcile and, when it will be retried, the retry
+func recordReconcileError(err error) {
+	class, reason := classifyError(err), errorReason(err)
+	reconcileErrors.WithLabelValues(string(class), reason).Inc()
+	if class == errorClassTransient {
+		reconcileRetries.WithLabelValues(reason).Inc()
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	// Labels put on every rendered ConfigMap so that they can be found across namespaces
+	labelManagedBy       = "app.kubernetes.io/managed-by"
+	labelConfigName      = "tracing.kubevishwa.io/config"
+	labelConfigNamespace = "tracing.kubevishwa.io/config-namespace"
+
+	managedByValue = "tracing-controller"
+
+	// cleanupFinalizer keeps a TracingConfig around until its ConfigMaps in other namespaces are gone
+	cleanupFinalizer = "tracing.kubevishwa.io/cleanup"
+)
+
+// configMapLabels identifies the ConfigMaps rendered for a TracingConfig
+func configMapLabels(tracingConfig *tracingv1.TracingConfig) map[string]string {
+	return map[string]string{
+		labelManagedBy:       managedByValue,
+		labelConfigName:      tracingConfig.Name,
+		labelConfigNamespace: tracingConfig.Namespace,
+	}
+}
+
+// checkLegacyNamespace keeps the deprecated namespace field to the TracingConfig's own namespace,
+// so that rendering into another namespace is always asked for through the namespaces list
+func checkLegacyNamespace(tracingConfig *tracingv1.TracingConfig) error {
+	if namespace := tracingConfig.Spec.Namespace; namespace != "" && namespace != tracingConfig.Namespace {
+		return fmt.Errorf("the deprecated namespace field only accepts the TracingConfig's own namespace %q, list %q in namespaces instead", tracingConfig.Namespace, namespace)
+	}
+	return nil
+}
+
+// resolveTargetNamespaces returns the sorted set of namespaces a TracingConfig applies to:
+// the legacy namespace field, the namespaces list and every namespace matching namespaceSelector.
+// Without any of them the TracingConfig applies to its own namespace.
+func (r *TracingConfigReconciler) resolveTargetNamespaces(ctx context.Context, tracingConfig *tracingv1.TracingConfig) (namespaces []string, err error) {
+	ctx, span := startSpan(ctx, "ResolveNamespaces")
+	defer func() {
+		span.SetAttributes(attribute.StringSlice("k8s.namespace.names", namespaces))
+		endSpan(span, err)
+	}()
+
+	if err := checkLegacyNamespace(tracingConfig); err != nil {
+		return nil, terminal("LegacyNamespaceOutOfScope", err)
+	}
+
+	spec := &tracingConfig.Spec
+	targets := map[string]bool{}
+	if spec.Namespace != "" {
+		targets[spec.Namespace] = true
+	}
+	for _, namespace := range spec.Namespaces {
+		targets[namespace] = true
+	}
+
+	if spec.NamespaceSelector != nil {
+		if r.namespaced() {
+			return nil, terminal("NamespaceSelectorUnsupported", fmt.Errorf("namespaceSelector needs a cluster-wide controller, list the namespaces instead"))
+		}
+		selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return nil, terminal("InvalidNamespaceSelector", err)
+		}
+		var namespaceList corev1.NamespaceList
+		if err := r.List(ctx, &namespaceList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, namespace := range namespaceList.Items {
+			if namespace.Status.Phase == corev1.NamespaceTerminating {
+				continue
+			}
+			targets[namespace.Name] = true
+		}
+	} else if len(targets) == 0 {
+		targets[tracingConfig.Namespace] = true
+	}
+
+	for namespace := range targets {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// cleanupNamespaces removes the rendered ConfigMap and Secret, and the references to them, from
+// every namespace that is not in keep. Passing no namespaces cleans up everywhere.
+func (r *TracingConfigReconciler) cleanupNamespaces(ctx context.Context, tracingConfig *tracingv1.TracingConfig, keep []string) (err error) {
+	ctx, span := startSpan(ctx, "CleanupNamespaces")
+	defer func() { endSpan(span, err) }()
+
+	inScope := map[string]bool{}
+	for _, namespace := range keep {
+		inScope[namespace] = true
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, client.MatchingLabels{
+		labelConfigName:      tracingConfig.Name,
+		labelConfigNamespace: tracingConfig.Namespace,
+	}); err != nil {
+		return fmt.Errorf("failed to list rendered ConfigMaps: %w", err)
+	}
+
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if inScope[configMap.Namespace] {
+			continue
+		}
+
+		// Detach the workloads first so that no new pod refers to a ConfigMap that is going away
+		if err := r.uninjectNamespace(ctx, configMap.Namespace, configMap.Name); err != nil {
+			return err
+		}
+		if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ConfigMap %s/%s: %w", configMap.Namespace, configMap.Name, err)
+		}
+		log.Printf("Deleted ConfigMap %s/%s, namespace is no longer targeted by TracingConfig %s/%s",
+			configMap.Namespace, configMap.Name, tracingConfig.Namespace, tracingConfig.Name)
+	}
+
+	// The Secrets go once the workloads no longer refer to them
+	var secrets corev1.SecretList
+	if err := r.List(ctx, &secrets, client.MatchingLabels{
+		labelConfigName:      tracingConfig.Name,
+		labelConfigNamespace: tracingConfig.Namespace,
+	}); err != nil {
+		return fmt.Errorf("failed to list rendered Secrets: %w", err)
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if inScope[secret.Namespace] {
+			continue
+		}
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		log.Printf("Deleted Secret %s/%s, namespace is no longer targeted by TracingConfig %s/%s",
+			secret.Namespace, secret.Name, tracingConfig.Namespace, tracingConfig.Name)
+	}
+	return nil
+}
+
+// uninjectNamespace removes the EnvFrom reference to configMapName, and the TLS mounts, from
+// every deployment in the namespace
+func (r *TracingConfigReconciler) uninjectNamespace(ctx context.Context, namespace, configMapName string) error {
+	ctx, span := startSpan(ctx, "UninjectNamespace",
+		semconv.K8SNamespaceNameKey.String(namespace),
+		attribute.String("k8s.configmap.name", configMapName),
+	)
+	defer span.End()
+
+	var deployments appsv1.DeploymentList
+	if err := r.List(ctx, &deployments, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !removeInjection(&deployment.Spec.Template, func(name string) bool { return name == configMapName }) {
+			continue
+		}
+		if err := r.Update(ctx, deployment); err != nil {
+			return fmt.Errorf("failed to remove tracing configuration from deployment %s/%s: %w", namespace, deployment.Name, err)
+		}
+		log.Printf("Removed tracing configuration from deployment %s/%s", namespace, deployment.Name)
+	}
+	return nil
+}
+
+// removeEnvFrom removes the EnvFrom references to the ConfigMaps matched by ours from the container
+func removeEnvFrom(container *corev1.Container, ours func(configMapName string) bool) bool {
+	removed := false
+	for i := 0; i < len(container.EnvFrom); i++ {
+		if ref := container.EnvFrom[i].ConfigMapRef; ref != nil && ours(ref.Name) {
+			container.EnvFrom = append(container.EnvFrom[:i], container.EnvFrom[i+1:]...)
+			i--
+			removed = true
+		}
+	}
+	return removed
+}
+
+// removeInjection strips the EnvFrom references to the ConfigMaps matched by ours, and with them
+// everything else injected into the pod template. It reports whether anything was referenced.
+func removeInjection(template *corev1.PodTemplateSpec, ours func(configMapName string) bool) bool {
+	updated := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if removeEnvFrom(container, ours) {
+			updated = true
+		}
+	}
+	if !updated {
+		return false
+	}
+
+	// The env vars the workload kept for itself stay
+	stashed := stashEnv(template, respectedEnv(template))
+	ensureSecretEnv(template, "", nil)
+	ensureTLSVolumes(&template.Spec, nil, nil)
+	ensureDebugOverride(template, nil, nil)
+	ensureServiceName(template, "", nil)
+	ensureResourceAttributes(template, nil, render.WorkloadReference{}, nil)
+	restoreEnv(template, stashed)
+	delete(template.Annotations, annotationRespectedEnv)
+	delete(template.Annotations, tracingv1.AnnotationConfigHash)
+	delete(template.Annotations, annotationRevision)
+	return true
+}
+
+// tracingConfigsForNamespace maps a Namespace event to every TracingConfig that selects
+// namespaces by label, so that labelling a namespace brings it into scope right away
+func (r *TracingConfigReconciler) tracingConfigsForNamespace(obj client.Object) []reconcile.Request {
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := r.List(context.Background(), &tracingConfigs); err != nil {
+		log.Printf("Failed to list TracingConfigs for namespace %s: %v", obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tracingConfig := range tracingConfigs.Items {
+		if tracingConfig.Spec.NamespaceSelector == nil {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: tracingConfig.Namespace, Name: tracingConfig.Name},
+		})
+	}
+	return requests
+}
+
+// tracingConfigsTargeting returns a request for every TracingConfig that last rendered its
+// configuration into namespace
+func (r *TracingConfigReconciler) tracingConfigsTargeting(ctx context.Context, namespace string) []reconcile.Request {
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := r.List(ctx, &tracingConfigs); err != nil {
+		log.Printf("Failed to list TracingConfigs targeting namespace %s: %v", namespace, err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tracingConfig := range tracingConfigs.Items {
+		targets := tracingConfig.Status.TargetNamespaces
+		if len(targets) == 0 {
+			targets = []string{tracingConfig.Namespace}
+		}
+		for _, target := range targets {
+			if target == namespace {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: tracingConfig.Namespace, Name: tracingConfig.Name},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+func TestResolveTargetNamespaces(t *testing.T) {
+	tests := []struct {
+		name            string
+		spec            tracingv1.TracingConfigSpec
+		watchNamespaces []string
+		want            []string
+		wantReason      string
+	}{
+		{name: "own namespace", want: []string{"default"}},
+		{name: "legacy own namespace", spec: tracingv1.TracingConfigSpec{Namespace: "default"}, want: []string{"default"}},
+		{name: "legacy other namespace", spec: tracingv1.TracingConfigSpec{Namespace: "payments"}, wantReason: "LegacyNamespaceOutOfScope"},
+		{name: "legacy other namespace with namespaces", spec: tracingv1.TracingConfigSpec{Namespace: "payments", Namespaces: []string{"payments"}}, wantReason: "LegacyNamespaceOutOfScope"},
+		{name: "namespaces", spec: tracingv1.TracingConfigSpec{Namespaces: []string{"payments", "checkout"}}, want: []string{"checkout", "payments"}},
+		{name: "selector in namespaced mode", spec: tracingv1.TracingConfigSpec{NamespaceSelector: &metav1.LabelSelector{}}, watchNamespaces: []string{"default"}, wantReason: "NamespaceSelectorUnsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TracingConfigReconciler{WatchNamespaces: tt.watchNamespaces}
+			tracingConfig := &tracingv1.TracingConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+				Spec:       tt.spec,
+			}
+
+			namespaces, err := r.resolveTargetNamespaces(context.Background(), tracingConfig)
+			if tt.wantReason != "" {
+				if err == nil || errorReason(err) != tt.wantReason {
+					t.Fatalf("resolveTargetNamespaces() = %v, %v, want %s", namespaces, err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(namespaces, tt.want) {
+				t.Errorf("resolveTargetNamespaces() = %v, %v, want %v", namespaces, err, tt.want)
+			}
+		})
+	}
+}
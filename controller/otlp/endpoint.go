@@ -0,0 +1,42 @@
+// Package otlp parses OTLP exporter endpoints the way the OpenTelemetry SDKs read them. It is
+// shared by the controller and the instrumented API so that both send spans to the same place.
+package otlp
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TracesPath is the traces signal's path, relative to the endpoint's base path
+const TracesPath = "/v1/traces"
+
+// Endpoint is an OTLP endpoint split into the parts the exporters expect
+type Endpoint struct {
+	// Host is the host and, when given, the port
+	Host string
+	// Path is the HTTP path traces are posted to
+	Path string
+	// Scheme is http or https when the endpoint is a URL, and empty otherwise
+	Scheme string
+}
+
+// ParseEndpoint accepts host:port, host:port/path or a URL, as set in OTEL_EXPORTER_OTLP_ENDPOINT.
+// Following the OTLP specification, the traces path is joined to the endpoint's base path, so
+// that http://gateway:4318/otel sends to /otel/v1/traces.
+func ParseEndpoint(endpoint string) (Endpoint, error) {
+	// Without a scheme the endpoint parses as a network-path reference
+	raw := endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	if u.Host == "" {
+		return Endpoint{}, fmt.Errorf("invalid OTLP endpoint %q: missing host", endpoint)
+	}
+	return Endpoint{Host: u.Host, Path: path.Join("/", u.Path, TracesPath), Scheme: u.Scheme}, nil
+}
@@ -0,0 +1,3 @@
+module tracing-controller/otlp
+
+go 1.18
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	conditionPolicyCompliant = "PolicyCompliant"
+	reasonPolicyCompliant    = "Compliant"
+	reasonPolicyClamped      = "Clamped"
+	reasonPolicyRejected     = "Rejected"
+	reasonPolicyViolation    = "PolicyViolation"
+)
+
+// policyReader returns the reader for the cluster-scoped TracingPolicies and Namespaces. A
+// namespace-scoped controller reads them uncached, so that it needs no cluster-wide watch.
+func (r *TracingConfigReconciler) policyReader() client.Reader {
+	if r.namespaced() && r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// policiesFor returns the TracingPolicies selecting any of the namespaces
+func (r *TracingConfigReconciler) policiesFor(ctx context.Context, namespaces []string) ([]tracingv1.TracingPolicy, error) {
+	reader := r.policyReader()
+	var policies tracingv1.TracingPolicyList
+	if err := reader.List(ctx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to list tracing policies: %w", err)
+	}
+
+	namespaceLabels := map[string]labels.Set{}
+	var matching []tracingv1.TracingPolicy
+	for _, policy := range policies.Items {
+		if policy.Spec.NamespaceSelector == nil {
+			matching = append(matching, policy)
+			continue
+		}
+		// A broken guardrail must not let everything through
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, terminal("InvalidPolicy", fmt.Errorf("TracingPolicy %s: %w", policy.Name, err))
+		}
+		for _, namespace := range namespaces {
+			set, ok := namespaceLabels[namespace]
+			if !ok {
+				var object corev1.Namespace
+				if err := reader.Get(ctx, types.NamespacedName{Name: namespace}, &object); client.IgnoreNotFound(err) != nil {
+					return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+				}
+				set = labels.Set(object.Labels)
+				namespaceLabels[namespace] = set
+			}
+			if selector.Matches(set) {
+				matching = append(matching, policy)
+				break
+			}
+		}
+	}
+	return matching, nil
+}
+
+// enforcePolicies checks the spec against the TracingPolicies selecting the target namespaces,
+// reports the violations and returns the spec to render, clamped where the policies allow it. A
+// spec that cannot be clamped fails with a terminal error.
+func (r *TracingConfigReconciler) enforcePolicies(ctx context.Context, tracingConfig *tracingv1.TracingConfig, spec tracingv1.TracingConfigSpec, namespaces []string) (_ tracingv1.TracingConfigSpec, err error) {
+	ctx, span := startSpan(ctx, "EnforcePolicies")
+	defer func() { endSpan(span, err) }()
+
+	policies, err := r.policiesFor(ctx, namespaces)
+	if err != nil {
+		return spec, err
+	}
+	evaluation := render.EvaluatePolicies(spec, policies)
+	span.SetAttributes(
+		attribute.Int("tracingpolicy.count", len(policies)),
+		attribute.Int("tracingpolicy.violations", len(evaluation.Violations)),
+	)
+
+	tracingConfig.Status.PolicyViolations = evaluation.Violations
+	tracingConfig.Status.MaxSamplingRate = evaluation.MaxSamplingRate
+	condition := metav1.Condition{
+		Type:               conditionPolicyCompliant,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonPolicyCompliant,
+		Message:            fmt.Sprintf("Within the limits of %d TracingPolicies", len(policies)),
+		ObservedGeneration: tracingConfig.Generation,
+	}
+	switch {
+	case evaluation.Rejected():
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonPolicyRejected
+		condition.Message = render.DescribeViolations(evaluation.RejectedViolations())
+		err = terminal("PolicyViolation", errors.New(condition.Message))
+	case len(evaluation.Violations) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonPolicyClamped
+		condition.Message = "Clamped to the policy limits: " + render.DescribeViolations(evaluation.Violations)
+		spec = evaluation.Spec
+	}
+	meta.SetStatusCondition(&tracingConfig.Status.Conditions, condition)
+
+	if condition.Status == metav1.ConditionFalse {
+		log.Printf("TracingConfig %s/%s violates tracing policies: %s", tracingConfig.Namespace, tracingConfig.Name, condition.Message)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(tracingConfig, corev1.EventTypeWarning, reasonPolicyViolation, "%s", condition.Message)
+		}
+	}
+	return spec, err
+}
+
+// tracingConfigsForPolicy maps a TracingPolicy change to every TracingConfig, since which ones
+// it selects depends on their target namespaces
+func (r *TracingConfigReconciler) tracingConfigsForPolicy(obj client.Object) []reconcile.Request {
+	var tracingConfigs tracingv1.TracingConfigList
+	if err := r.List(context.Background(), &tracingConfigs); err != nil {
+		log.Printf("Failed to list TracingConfigs for policy %s: %v", obj.GetName(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tracingConfig := range tracingConfigs.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: tracingConfig.Namespace, Name: tracingConfig.Name},
+		})
+	}
+	return requests
+}
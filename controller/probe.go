@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/otlp"
+)
+
+const (
+	conditionEndpointReachable = "EndpointReachable"
+
+	reasonExportSucceeded  = "ExportSucceeded"
+	reasonInvalidEndpoint  = "InvalidEndpoint"
+	reasonConnectionFailed = "ConnectionFailed"
+	reasonExportFailed     = "ExportFailed"
+	reasonTLSConfigError   = "TLSConfigError"
+
+	defaultProbeTimeout = 5 * time.Second
+)
+
+// EndpointProber checks that an OTLP endpoint accepts TCP connections and an empty Export call
+type EndpointProber struct {
+	Timeout time.Duration
+}
+
+// probeTarget is everything needed to reach an OTLP endpoint the way the workloads will
+type probeTarget struct {
+	Endpoint string
+	Protocol string
+	Headers  map[string]string
+	TLS      *tls.Config
+}
+
+// probeResult is the outcome of a single probe; Err is nil when the endpoint is reachable
+type probeResult struct {
+	Reason  string
+	Latency time.Duration
+	Err     error
+}
+
+// Probe dials the endpoint and sends an empty ExportTraceServiceRequest over the configured protocol
+func (p *EndpointProber) Probe(ctx context.Context, target probeTarget) probeResult {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address, path, scheme, err := splitOTLPEndpoint(target.Endpoint, target.Protocol)
+	if err != nil {
+		return probeResult{Reason: reasonInvalidEndpoint, Err: err}
+	}
+
+	// An explicit scheme on the endpoint wins over the TLS settings, as it does in the SDK
+	tlsConfig := target.TLS
+	switch scheme {
+	case "http":
+		tlsConfig = nil
+	case "https":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+	}
+
+	start := time.Now()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return probeResult{Reason: reasonConnectionFailed, Err: err}
+	}
+	conn.Close()
+
+	if target.Protocol == "http/protobuf" {
+		err = probeHTTPExport(ctx, address, path, target.Headers, tlsConfig)
+	} else {
+		err = probeGRPCExport(ctx, address, target.Headers, tlsConfig)
+	}
+	if err != nil {
+		return probeResult{Reason: reasonExportFailed, Err: err}
+	}
+
+	return probeResult{Reason: reasonExportSucceeded, Latency: time.Since(start)}
+}
+
+func probeGRPCExport(ctx context.Context, address string, headers map[string]string, tlsConfig *tls.Config) error {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("failed to establish gRPC connection: %w", err)
+	}
+	defer conn.Close()
+
+	if len(headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(headers))
+	}
+	if _, err := coltracepb.NewTraceServiceClient(conn).Export(ctx, &coltracepb.ExportTraceServiceRequest{}); err != nil {
+		return fmt.Errorf("export rejected: %w", err)
+	}
+	return nil
+}
+
+func probeHTTPExport(ctx context.Context, address, path string, headers map[string]string, tlsConfig *tls.Config) error {
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	endpointURL := url.URL{Scheme: scheme, Host: address, Path: path}
+
+	// An empty protobuf message serializes to zero bytes
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL.String(), bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	// Probes are minutes apart and the TLS settings are rebuilt for each, so keep no connection open
+	transport := &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("export rejected with HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// splitOTLPEndpoint turns host:port or a URL into a dialable address, the HTTP traces path and the scheme
+func splitOTLPEndpoint(endpoint, protocol string) (address, path, scheme string, err error) {
+	defaultPort := "4317"
+	if protocol == "http/protobuf" {
+		defaultPort = "4318"
+	}
+
+	target, err := otlp.ParseEndpoint(endpoint)
+	if err != nil {
+		return "", "", "", err
+	}
+	address = target.Host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+	return address, target.Path, target.Scheme, nil
+}
+
+// probeTLSConfig builds the client TLS configuration from the same Secrets that get mounted into workloads
+func (r *TracingConfigReconciler) probeTLSConfig(ctx context.Context, namespace string, spec *tracingv1.TracingTLSConfig) (*tls.Config, error) {
+	if spec == nil || spec.Insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if spec.CASecretName != "" {
+		var secret corev1.Secret
+		if err := r.sourceSecretReader().Get(ctx, types.NamespacedName{Name: spec.CASecretName, Namespace: namespace}, &secret); err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(secret.Data[spec.CASecretKey()]) {
+			return nil, fmt.Errorf("no certificates found in Secret %s/%s", namespace, spec.CASecretName)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if spec.ClientCertSecretName != "" {
+		var secret corev1.Secret
+		if err := r.sourceSecretReader().Get(ctx, types.NamespacedName{Name: spec.ClientCertSecretName, Namespace: namespace}, &secret); err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in Secret %s/%s: %w", namespace, spec.ClientCertSecretName, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// probeEndpoint probes the configured endpoint, records the outcome in the endpoint gauges and
+// returns it as the EndpointReachable condition
+func (r *TracingConfigReconciler) probeEndpoint(ctx context.Context, prober *EndpointProber, tracingConfig *tracingv1.TracingConfig, spec *tracingv1.TracingConfigSpec, namespace string) metav1.Condition {
+	result := probeResult{Reason: reasonTLSConfigError}
+	tlsConfig, err := r.probeTLSConfig(ctx, namespace, spec.TLS)
+	if err != nil {
+		result.Err = err
+	} else if secretHeaders, _, err := r.readSourceSecrets(ctx, namespace, spec); err != nil {
+		result.Err = err
+	} else {
+		// Send the headers the workloads send, including those from the headers Secret
+		headers := make(map[string]string, len(spec.Headers)+len(secretHeaders))
+		for key, value := range spec.Headers {
+			headers[key] = value
+		}
+		for key, value := range secretHeaders {
+			headers[key] = value
+		}
+		result = prober.Probe(ctx, probeTarget{
+			Endpoint: spec.Endpoint,
+			Protocol: spec.Protocol,
+			Headers:  headers,
+			TLS:      tlsConfig,
+		})
+	}
+
+	condition := metav1.Condition{
+		Type:               conditionEndpointReachable,
+		Reason:             result.Reason,
+		ObservedGeneration: tracingConfig.Generation,
+	}
+	if result.Err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = fmt.Sprintf("Endpoint %s is not reachable: %v", spec.Endpoint, result.Err)
+		endpointReachable.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name).Set(0)
+	} else {
+		condition.Status = metav1.ConditionTrue
+		condition.Message = fmt.Sprintf("Endpoint %s accepted an empty export in %s", spec.Endpoint, result.Latency.Round(time.Millisecond))
+		endpointReachable.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name).Set(1)
+		endpointProbeLatency.WithLabelValues(tracingConfig.Namespace, tracingConfig.Name).Set(result.Latency.Seconds())
+	}
+	return condition
+}
+
+// EndpointProbes probes the endpoints of the enabled TracingConfigs on a ticker of its own, so
+// that a reconcile never waits on a collector and probing never re-renders anything
+type EndpointProbes struct {
+	Reconciler *TracingConfigReconciler
+	Prober     *EndpointProber
+	Interval   time.Duration
+
+	mu sync.Mutex
+	// targets maps each TracingConfig to probe to the namespace whose Secrets the probe uses
+	targets map[types.NamespacedName]string
+}
+
+// track probes the TracingConfig's endpoint with the Secrets of the given namespace from now on
+func (p *EndpointProbes) track(key types.NamespacedName, namespace string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.targets == nil {
+		p.targets = make(map[types.NamespacedName]string)
+	}
+	p.targets[key] = namespace
+}
+
+// forget stops probing the TracingConfig's endpoint
+func (p *EndpointProbes) forget(key types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.targets, key)
+}
+
+// Start probes every tracked endpoint each interval until the manager stops
+func (p *EndpointProbes) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.probeAll(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection leaves probing, and the status writes it makes, to the leader
+func (p *EndpointProbes) NeedLeaderElection() bool {
+	return true
+}
+
+// probeAll probes the tracked endpoints one after another
+func (p *EndpointProbes) probeAll(ctx context.Context) {
+	p.mu.Lock()
+	targets := make(map[types.NamespacedName]string, len(p.targets))
+	for key, namespace := range p.targets {
+		targets[key] = namespace
+	}
+	p.mu.Unlock()
+
+	for key, namespace := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := p.probe(ctx, key, namespace); err != nil {
+			log.Printf("Failed to probe the endpoint of TracingConfig %s: %v", key, err)
+		}
+	}
+}
+
+// probe probes one TracingConfig's endpoint and writes the EndpointReachable condition, leaving
+// the rest of its status to the reconcile
+func (p *EndpointProbes) probe(ctx context.Context, key types.NamespacedName, namespace string) (err error) {
+	ctx, span := startSpan(ctx, "ProbeEndpoint", attribute.String("tracingconfig.name", key.Name))
+	defer func() { endSpan(span, err) }()
+
+	var tracingConfig tracingv1.TracingConfig
+	if err := p.Reconciler.Get(ctx, key, &tracingConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			p.forget(key)
+			return nil
+		}
+		return err
+	}
+	// Probe the endpoint the workloads were rendered with, TracingDefaults included
+	spec := &tracingConfig.Spec
+	if tracingConfig.Status.ResolvedSpec != nil {
+		spec = tracingConfig.Status.ResolvedSpec
+	}
+	span.SetAttributes(attribute.String("otlp.endpoint", spec.Endpoint))
+	condition := p.Reconciler.probeEndpoint(ctx, p.Prober, &tracingConfig, spec, namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := p.Reconciler.Get(ctx, key, &tracingConfig); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		meta.SetStatusCondition(&tracingConfig.Status.Conditions, condition)
+		return client.IgnoreNotFound(p.Reconciler.Status().Update(ctx, &tracingConfig))
+	})
+}
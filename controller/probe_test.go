@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+func TestSplitOTLPEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, protocol    string
+		address, path, scheme string
+		wantErr               bool
+	}{
+		{endpoint: "collector:4317", protocol: "grpc", address: "collector:4317", path: "/v1/traces"},
+		{endpoint: "collector", protocol: "grpc", address: "collector:4317", path: "/v1/traces"},
+		{endpoint: "collector", protocol: "http/protobuf", address: "collector:4318", path: "/v1/traces"},
+		{endpoint: "collector:4318/otel", protocol: "http/protobuf", address: "collector:4318", path: "/otel/v1/traces"},
+		{endpoint: "https://gateway:443/otel/", protocol: "http/protobuf", address: "gateway:443", path: "/otel/v1/traces", scheme: "https"},
+		{endpoint: "https://collector", protocol: "http/protobuf", address: "collector:4318", path: "/v1/traces", scheme: "https"},
+		{endpoint: "http://collector:9000/", protocol: "grpc", address: "collector:9000", path: "/v1/traces", scheme: "http"},
+		{endpoint: "http://", protocol: "grpc", wantErr: true},
+	}
+	for _, tt := range tests {
+		address, path, scheme, err := splitOTLPEndpoint(tt.endpoint, tt.protocol)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitOTLPEndpoint(%q) succeeded, want an error", tt.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitOTLPEndpoint(%q) failed: %v", tt.endpoint, err)
+			continue
+		}
+		if address != tt.address || path != tt.path || scheme != tt.scheme {
+			t.Errorf("splitOTLPEndpoint(%q) = %q, %q, %q, want %q, %q, %q", tt.endpoint, address, path, scheme, tt.address, tt.path, tt.scheme)
+		}
+	}
+}
+
+func TestProbeHTTPExport(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		if len(body) != 0 {
+			t.Errorf("export body has %d bytes, want an empty request", len(body))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{
+		Endpoint: server.Listener.Addr().String(),
+		Protocol: "http/protobuf",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	if result.Err != nil || result.Reason != reasonExportSucceeded {
+		t.Fatalf("Probe() = %s, %v, want %s", result.Reason, result.Err, reasonExportSucceeded)
+	}
+	if got.Method != http.MethodPost || got.URL.Path != "/v1/traces" {
+		t.Errorf("export sent as %s %s, want POST /v1/traces", got.Method, got.URL.Path)
+	}
+	if contentType := got.Header.Get("Content-Type"); contentType != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q, want application/x-protobuf", contentType)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want the configured header", auth)
+	}
+	if !got.Close {
+		t.Error("export kept its connection open")
+	}
+}
+
+func TestProbeHTTPExportRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{Endpoint: server.URL, Protocol: "http/protobuf"})
+	if result.Reason != reasonExportFailed || result.Err == nil || !strings.Contains(result.Err.Error(), "401") {
+		t.Fatalf("Probe() = %s, %v, want %s with HTTP 401", result.Reason, result.Err, reasonExportFailed)
+	}
+}
+
+func TestProbeHTTPExportTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	// The untrusted probe fails the handshake on purpose
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{
+		Endpoint: server.Listener.Addr().String(),
+		Protocol: "http/protobuf",
+		TLS:      &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	})
+	if result.Err != nil || result.Reason != reasonExportSucceeded {
+		t.Fatalf("Probe() = %s, %v, want %s", result.Reason, result.Err, reasonExportSucceeded)
+	}
+
+	// Without the CA the server's certificate is not trusted
+	result = prober.Probe(context.Background(), probeTarget{Endpoint: server.URL, Protocol: "http/protobuf"})
+	if result.Reason != reasonExportFailed {
+		t.Fatalf("Probe() without the CA = %s, %v, want %s", result.Reason, result.Err, reasonExportFailed)
+	}
+}
+
+func TestProbeConnectionFailed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{Endpoint: address, Protocol: "grpc"})
+	if result.Reason != reasonConnectionFailed || result.Err == nil {
+		t.Fatalf("Probe() = %s, %v, want %s", result.Reason, result.Err, reasonConnectionFailed)
+	}
+}
+
+// traceService accepts or rejects exports and records the metadata they carry
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	err      error
+	metadata metadata.MD
+}
+
+func (s *traceService) Export(ctx context.Context, _ *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.metadata, _ = metadata.FromIncomingContext(ctx)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// serveTraceService serves the trace service over gRPC on a local port
+func serveTraceService(t *testing.T, service *traceService) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, service)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestProbeGRPCExport(t *testing.T) {
+	service := &traceService{}
+	address := serveTraceService(t, service)
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{
+		Endpoint: address,
+		Protocol: "grpc",
+		Headers:  map[string]string{"x-api-key": "secret"},
+	})
+	if result.Err != nil || result.Reason != reasonExportSucceeded {
+		t.Fatalf("Probe() = %s, %v, want %s", result.Reason, result.Err, reasonExportSucceeded)
+	}
+	if key := service.metadata.Get("x-api-key"); len(key) != 1 || key[0] != "secret" {
+		t.Errorf("x-api-key metadata = %v, want the configured header", key)
+	}
+}
+
+func TestProbeGRPCExportRejected(t *testing.T) {
+	address := serveTraceService(t, &traceService{err: status.Error(codes.Unauthenticated, "missing api key")})
+
+	prober := &EndpointProber{Timeout: time.Second}
+	result := prober.Probe(context.Background(), probeTarget{Endpoint: address, Protocol: "grpc"})
+	if result.Reason != reasonExportFailed || result.Err == nil || !strings.Contains(result.Err.Error(), "missing api key") {
+		t.Fatalf("Probe() = %s, %v, want %s with Unauthenticated", result.Reason, result.Err, reasonExportFailed)
+	}
+}
+
+func TestEndpointProbesWriteOnlyTheCondition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	if err := tracingv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	tracingConfig := &tracingv1.TracingConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+		Spec:       tracingv1.TracingConfigSpec{Enabled: true, Endpoint: server.URL, Protocol: "http/protobuf"},
+		Status:     tracingv1.TracingConfigStatus{Phase: "Applied"},
+	}
+	r := &TracingConfigReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tracingConfig).Build()}
+	probes := &EndpointProbes{Reconciler: r, Prober: &EndpointProber{Timeout: time.Second}, Interval: time.Minute}
+	key := types.NamespacedName{Namespace: "default", Name: "checkout"}
+	probes.track(key, "default")
+
+	probes.probeAll(context.Background())
+
+	var got tracingv1.TracingConfig
+	if err := r.Get(context.Background(), key, &got); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(got.Status.Conditions, conditionEndpointReachable)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonExportSucceeded {
+		t.Fatalf("EndpointReachable = %+v, want True with %s", condition, reasonExportSucceeded)
+	}
+	if got.Status.Phase != "Applied" {
+		t.Errorf("phase = %q, want the reconcile's phase left alone", got.Status.Phase)
+	}
+
+	// A TracingConfig that is gone is no longer probed
+	if err := r.Delete(context.Background(), &got); err != nil {
+		t.Fatal(err)
+	}
+	probes.probeAll(context.Background())
+	if len(probes.targets) != 0 {
+		t.Errorf("targets = %v, want the deleted TracingConfig forgotten", probes.targets)
+	}
+}
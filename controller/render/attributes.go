@@ -0,0 +1,92 @@
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// downwardEnvPrefix prefixes the downward API env vars referenced from OTEL_RESOURCE_ATTRIBUTES
+const downwardEnvPrefix = "TRACING_"
+
+// downwardAttributes maps k8s.* resource attributes to the pod fields the downward API exposes
+var downwardAttributes = []struct {
+	Attribute string
+	Env       string
+	FieldPath string
+}{
+	{"k8s.namespace.name", downwardEnvPrefix + "NAMESPACE_NAME", "metadata.namespace"},
+	{"k8s.pod.name", downwardEnvPrefix + "POD_NAME", "metadata.name"},
+	{"k8s.pod.uid", downwardEnvPrefix + "POD_UID", "metadata.uid"},
+	{"k8s.node.name", downwardEnvPrefix + "NODE_NAME", "spec.nodeName"},
+}
+
+// ResourceAttributeEnv returns the env vars that expose the pod's identity and mapped labels and
+// annotations through the downward API and fold them, together with the spec's attributes, into
+// OTEL_RESOURCE_ATTRIBUTES. Later attributes take precedence: pod metadata over the spec's
+// attributes, and the k8s.* attributes over both.
+func ResourceAttributeEnv(spec *v1.TracingConfigSpec, workload WorkloadReference, container string) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	var attributes []string
+
+	keys := make([]string, 0, len(spec.Attributes))
+	for key := range spec.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attributes = append(attributes, key+"="+url.QueryEscape(spec.Attributes[key]))
+	}
+
+	// Dependent variables must be defined before the variable referencing them
+	env, attributes = fromPodMetadata(env, attributes, "LABEL", "metadata.labels", spec.AttributesFromLabels)
+	env, attributes = fromPodMetadata(env, attributes, "ANNOTATION", "metadata.annotations", spec.AttributesFromAnnotations)
+	for _, attribute := range downwardAttributes {
+		env = append(env, corev1.EnvVar{
+			Name:      attribute.Env,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: attribute.FieldPath}},
+		})
+		attributes = append(attributes, attribute.Attribute+"=$("+attribute.Env+")")
+	}
+	if workload.Kind == "Deployment" {
+		attributes = append(attributes, "k8s.deployment.name="+url.QueryEscape(workload.Name))
+	}
+	attributes = append(attributes, "k8s.container.name="+url.QueryEscape(container))
+
+	return append(env, corev1.EnvVar{Name: "OTEL_RESOURCE_ATTRIBUTES", Value: strings.Join(attributes, ",")})
+}
+
+// fromPodMetadata adds a downward API env var per mapped label or annotation and references it
+// from the attributes. Label values are always safe to embed; annotation values must not contain
+// commas.
+func fromPodMetadata(env []corev1.EnvVar, attributes []string, kind, field string, mapping map[string]string) ([]corev1.EnvVar, []string) {
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		name := fmt.Sprintf("%s%s_%d", downwardEnvPrefix, kind, i)
+		env = append(env, corev1.EnvVar{
+			Name:      name,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: fmt.Sprintf("%s['%s']", field, key)}},
+		})
+		attribute := mapping[key]
+		if attribute == "" {
+			attribute = key
+		}
+		attributes = append(attributes, attribute+"=$("+name+")")
+	}
+	return env, attributes
+}
+
+// IsResourceAttributeEnv reports whether the env var is one of those set by ResourceAttributeEnv
+func IsResourceAttributeEnv(name string) bool {
+	return name == "OTEL_RESOURCE_ATTRIBUTES" || strings.HasPrefix(name, downwardEnvPrefix)
+}
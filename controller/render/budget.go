@@ -0,0 +1,73 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	v1 "tracing-controller/api/v1"
+)
+
+const (
+	// DefaultBudgetInterval is the least time between two adjustments to a span budget
+	DefaultBudgetInterval = 10 * time.Minute
+	// MaxBudgetDecisions is how many adjustments status keeps
+	MaxBudgetDecisions = 10
+
+	defaultMinBudgetSamplingRate = 0.01
+	// budgetTolerance is how far the throughput may stray from the budget, relative to it,
+	// before the sampling rate is adjusted
+	budgetTolerance = 0.1
+)
+
+// BudgetBounds returns the sampling rates a budget adjusts between
+func BudgetBounds(budget *v1.SpanBudget) (min, max float64) {
+	min, max = defaultMinBudgetSamplingRate, 1
+	if budget.MinSamplingRate != nil && *budget.MinSamplingRate > 0 {
+		min = *budget.MinSamplingRate
+	}
+	if budget.MaxSamplingRate != nil {
+		max = *budget.MaxSamplingRate
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// BudgetInterval returns the least time between two adjustments to the budget
+func BudgetInterval(budget *v1.SpanBudget) (time.Duration, error) {
+	if budget.Interval == "" {
+		return DefaultBudgetInterval, nil
+	}
+	interval, err := time.ParseDuration(budget.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid budget interval %q: %w", budget.Interval, err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("budget interval %q must be positive", budget.Interval)
+	}
+	return interval, nil
+}
+
+// BudgetSamplingRate picks the sampling rate that brings the throughput observed while current
+// was rendered to the budget, assuming throughput scales linearly with the rate. It reports
+// false when the throughput is within the tolerance of the budget or the rate is at its bound.
+func BudgetSamplingRate(budget *v1.SpanBudget, current, observed, min, max float64) (float64, bool) {
+	if observed <= 0 || current <= 0 || math.Abs(observed-budget.SpansPerSecond) <= budgetTolerance*budget.SpansPerSecond {
+		return current, false
+	}
+	rate := math.Round(current*budget.SpansPerSecond/observed*1e4) / 1e4
+	rate = math.Max(min, math.Min(max, rate))
+	return rate, rate != current
+}
+
+// ApplyBudget renders the sampling rate chosen for the spec's budget, unless the active schedule
+// window sets one
+func ApplyBudget(spec v1.TracingConfigSpec, status *v1.BudgetStatus, active ActiveSchedule) v1.TracingConfigSpec {
+	if spec.Budget == nil || status == nil || active.Window != nil && active.Window.SamplingRate != nil {
+		return spec
+	}
+	spec.SamplingRate = status.SamplingRate
+	return spec
+}
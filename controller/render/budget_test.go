@@ -0,0 +1,77 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestBudgetSamplingRate(t *testing.T) {
+	budget := &v1.SpanBudget{SpansPerSecond: 100}
+
+	tests := []struct {
+		name              string
+		current, observed float64
+		min, max          float64
+		want              float64
+		changed           bool
+	}{
+		{name: "over budget", current: 0.5, observed: 200, min: 0.01, max: 1, want: 0.25, changed: true},
+		{name: "under budget", current: 0.1, observed: 40, min: 0.01, max: 1, want: 0.25, changed: true},
+		{name: "within tolerance", current: 0.5, observed: 109, min: 0.01, max: 1, want: 0.5},
+		{name: "no throughput", current: 0.5, observed: 0, min: 0.01, max: 1, want: 0.5},
+		{name: "clamped to max", current: 0.5, observed: 20, min: 0.01, max: 0.8, want: 0.8, changed: true},
+		{name: "clamped to min", current: 0.02, observed: 10000, min: 0.01, max: 1, want: 0.01, changed: true},
+		{name: "at the bound", current: 1, observed: 50, min: 0.01, max: 1, want: 1},
+		{name: "rounded", current: 0.3, observed: 700, min: 0.0001, max: 1, want: 0.0429, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, changed := BudgetSamplingRate(budget, tt.current, tt.observed, tt.min, tt.max)
+			if rate != tt.want || changed != tt.changed {
+				t.Errorf("BudgetSamplingRate() = %g, %v, want %g, %v", rate, changed, tt.want, tt.changed)
+			}
+			if rate < tt.min {
+				t.Errorf("BudgetSamplingRate() = %g, below the min of %g", rate, tt.min)
+			}
+		})
+	}
+}
+
+func TestBudgetBounds(t *testing.T) {
+	low, high, zero := 0.2, 0.1, 0.0
+
+	tests := []struct {
+		name     string
+		budget   v1.SpanBudget
+		min, max float64
+	}{
+		{name: "defaults", min: defaultMinBudgetSamplingRate, max: 1},
+		{name: "explicit", budget: v1.SpanBudget{MinSamplingRate: &high, MaxSamplingRate: &low}, min: 0.1, max: 0.2},
+		{name: "zero min", budget: v1.SpanBudget{MinSamplingRate: &zero}, min: defaultMinBudgetSamplingRate, max: 1},
+		{name: "max below min", budget: v1.SpanBudget{MinSamplingRate: &low, MaxSamplingRate: &high}, min: 0.2, max: 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			min, max := BudgetBounds(&tt.budget)
+			if min != tt.min || max != tt.max {
+				t.Errorf("BudgetBounds() = %g, %g, want %g, %g", min, max, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestBudgetInterval(t *testing.T) {
+	if interval, err := BudgetInterval(&v1.SpanBudget{}); err != nil || interval != DefaultBudgetInterval {
+		t.Errorf("BudgetInterval() = %v, %v, want the default", interval, err)
+	}
+	if interval, err := BudgetInterval(&v1.SpanBudget{Interval: "30m"}); err != nil || interval != 30*time.Minute {
+		t.Errorf("BudgetInterval(30m) = %v, %v, want 30m", interval, err)
+	}
+	for _, invalid := range []string{"soon", "-5m", "0s"} {
+		if _, err := BudgetInterval(&v1.SpanBudget{Interval: invalid}); err == nil {
+			t.Errorf("BudgetInterval(%q) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package render turns TracingConfig specs into the OTEL_* environment seen by workloads. It is
+// shared by the controller and the kubectl-tracing plugin so that both agree on the result.
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// ConfigMapData renders the OTEL_* environment variables for a TracingConfig spec. A templated
+// service name is left out, it is set on each workload instead.
+func ConfigMapData(spec *v1.TracingConfigSpec) map[string]string {
+	data := map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": spec.Endpoint,
+		"OTEL_TRACES_SAMPLER":         "traceidratio",
+		"OTEL_TRACES_SAMPLER_ARG":     FormatSamplingRate(spec.SamplingRate),
+		"OTEL_SDK_DISABLED":           strconv.FormatBool(!spec.Enabled),
+	}
+	if !IsServiceNameTemplate(spec.ServiceName) {
+		data["OTEL_SERVICE_NAME"] = spec.ServiceName
+	}
+
+	// Add optional configurations
+	protocol := spec.Protocol
+	if protocol == "" {
+		protocol = "grpc"
+	}
+	data["OTEL_EXPORTER_OTLP_PROTOCOL"] = protocol
+	if spec.Compression != "" {
+		data["OTEL_EXPORTER_OTLP_COMPRESSION"] = spec.Compression
+	}
+	if spec.ExportTimeout != "" {
+		data["OTEL_EXPORTER_OTLP_TIMEOUT"] = spec.ExportTimeout
+	}
+	if spec.BatchTimeout != "" {
+		data["OTEL_BSP_SCHEDULE_DELAY"] = spec.BatchTimeout
+	}
+	if spec.MaxBatchSize > 0 {
+		data["OTEL_BSP_MAX_EXPORT_BATCH_SIZE"] = fmt.Sprintf("%d", spec.MaxBatchSize)
+	}
+
+	// Add custom attributes
+	for key, value := range spec.Attributes {
+		data[fmt.Sprintf("OTEL_RESOURCE_ATTRIBUTES_%s", key)] = value
+	}
+
+	// Add transport security settings
+	for key, value := range TLSEnv(spec.TLS) {
+		data[key] = value
+	}
+
+	return data
+}
+
+// FormatSamplingRate formats a sampling ratio the way it is passed to OTEL_TRACES_SAMPLER_ARG, at
+// full precision so that the pods sample at exactly the rate that was computed
+func FormatSamplingRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// ConfigHash returns a short, stable hash of the rendered configuration
+func ConfigHash(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", key, data[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// RevisionHash identifies a revision of the configuration: the rendered data plus the parts of the
+// spec that are rendered onto the workloads rather than into the ConfigMap
+func RevisionHash(spec *v1.TracingConfigSpec, data map[string]string) string {
+	if len(spec.AttributesFromLabels) == 0 && len(spec.AttributesFromAnnotations) == 0 &&
+		len(spec.Containers) == 0 && len(spec.ExcludeContainers) == 0 && spec.RespectsEnv() {
+		return ConfigHash(data)
+	}
+	hashed := make(map[string]string, len(data)+len(spec.AttributesFromLabels)+len(spec.AttributesFromAnnotations)+2)
+	for key, value := range data {
+		hashed[key] = value
+	}
+	for key, attribute := range spec.AttributesFromLabels {
+		hashed["label:"+key] = attribute
+	}
+	for key, attribute := range spec.AttributesFromAnnotations {
+		hashed["annotation:"+key] = attribute
+	}
+	if len(spec.Containers) > 0 {
+		hashed["containers"] = strings.Join(spec.Containers, ",")
+	}
+	if len(spec.ExcludeContainers) > 0 {
+		hashed["excludeContainers"] = strings.Join(spec.ExcludeContainers, ",")
+	}
+	if !spec.RespectsEnv() {
+		hashed["envConflictPolicy"] = spec.EnvConflictPolicy
+	}
+	return ConfigHash(hashed)
+}
+
+// ConfigMapName returns the name of the ConfigMap rendered for a TracingConfig in every target namespace
+func ConfigMapName(tracingConfig *v1.TracingConfig) string {
+	return fmt.Sprintf("%s-tracing-config", tracingConfig.Name)
+}
+
+// RevisionConfigMapName returns the name of the ConfigMap holding one revision of the configuration
+func RevisionConfigMapName(base, revision string) string {
+	return fmt.Sprintf("%s-%s", base, revision)
+}
+
+// IsTracingConfigMap reports whether name is the base ConfigMap of a TracingConfig or one of its revisions
+func IsTracingConfigMap(name, base string) bool {
+	if name == base {
+		return true
+	}
+	revision := strings.TrimPrefix(name, base+"-")
+	if revision == name || len(revision) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(revision)
+	return err == nil
+}
@@ -0,0 +1,49 @@
+package render
+
+import (
+	"testing"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestFormatSamplingRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want string
+	}{
+		{rate: 1, want: "1"},
+		{rate: 0.1, want: "0.1"},
+		{rate: 0.25, want: "0.25"},
+		{rate: 0.0429, want: "0.0429"},
+		{rate: 0.001, want: "0.001"},
+		{rate: 0, want: "0"},
+	}
+	for _, tt := range tests {
+		if got := FormatSamplingRate(tt.rate); got != tt.want {
+			t.Errorf("FormatSamplingRate(%g) = %q, want %q", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestConfigMapDataRendersBudgetRate(t *testing.T) {
+	budget := &v1.SpanBudget{SpansPerSecond: 100}
+
+	tests := []struct {
+		name              string
+		current, observed float64
+		min               float64
+		want              string
+	}{
+		{name: "four decimals", current: 0.3, observed: 700, min: 0.0001, want: "0.0429"},
+		{name: "at a small min", current: 0.002, observed: 1000, min: 0.001, want: "0.001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, _ := BudgetSamplingRate(budget, tt.current, tt.observed, tt.min, 1)
+			spec := ApplyBudget(v1.TracingConfigSpec{Enabled: true, Budget: budget}, &v1.BudgetStatus{SamplingRate: rate}, ActiveSchedule{})
+			if got := ConfigMapData(&spec)["OTEL_TRACES_SAMPLER_ARG"]; got != tt.want {
+				t.Errorf("OTEL_TRACES_SAMPLER_ARG = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package render
+
+import (
+	v1 "tracing-controller/api/v1"
+)
+
+// DefaultExcludedContainers are well-known sidecars that are never injected unless listed in
+// spec.containers: their traces would only be noise
+var DefaultExcludedContainers = []string{
+	"istio-proxy",
+	"linkerd-proxy",
+	"envoy",
+	"cloud-sql-proxy",
+	"vault-agent",
+	"fluent-bit",
+	"fluentd",
+	"filebeat",
+	"otc-container",
+}
+
+// TargetsContainer reports whether the spec injects the container with the given name. An
+// explicit containers list wins; otherwise every container is injected except those listed in
+// excludeContainers and the default sidecar exclusions.
+func TargetsContainer(spec *v1.TracingConfigSpec, name string) bool {
+	if len(spec.Containers) > 0 {
+		return contains(spec.Containers, name)
+	}
+	return !contains(spec.ExcludeContainers, name) && !contains(DefaultExcludedContainers, name)
+}
+
+func contains(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// DebugOverride is the part of an active debug session that is layered onto a workload
+type DebugOverride struct {
+	Session      string
+	SamplingRate float64
+}
+
+// ActiveDebugSessions returns the sessions that are active at now, sorted by name so that
+// overlapping sessions always resolve the same way
+func ActiveDebugSessions(sessions []v1.TracingDebugSession, now time.Time) []v1.TracingDebugSession {
+	var active []v1.TracingDebugSession
+	for _, session := range sessions {
+		if session.Status.Phase == v1.DebugSessionActive && session.Status.ExpiresAt != nil && now.Before(session.Status.ExpiresAt.Time) {
+			active = append(active, session)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Name < active[j].Name })
+	return active
+}
+
+// DebugOverrideFor picks the first active session selecting the deployment's pods
+func DebugOverrideFor(sessions []v1.TracingDebugSession, deployment *appsv1.Deployment) *DebugOverride {
+	podLabels := labels.Set(deployment.Spec.Template.Labels)
+	for i := range sessions {
+		selector, err := metav1.LabelSelectorAsSelector(sessions[i].Spec.Selector)
+		if err != nil || !selector.Matches(podLabels) {
+			continue
+		}
+		return &DebugOverride{Session: sessions[i].Name, SamplingRate: sessions[i].EffectiveSamplingRate()}
+	}
+	return nil
+}
+
+// WithDebugOverride returns the rendered data as the overridden workload will see it
+func WithDebugOverride(data map[string]string, override *DebugOverride) map[string]string {
+	if override == nil {
+		return data
+	}
+	merged := make(map[string]string, len(data)+1)
+	for key, value := range data {
+		merged[key] = value
+	}
+	merged["OTEL_TRACES_SAMPLER_ARG"] = FormatSamplingRate(override.SamplingRate)
+	return merged
+}
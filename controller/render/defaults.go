@@ -0,0 +1,77 @@
+package render
+
+import (
+	"sort"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// ApplyDefaults fills the fields the spec leaves unset from the TracingDefaults, applied in name
+// order so that later ones win. Headers and attributes are merged key by key. It also returns,
+// per defaulted spec field (e.g. "spec.endpoint" or "spec.headers.x-api-key"), the name of the
+// TracingDefaults it came from.
+func ApplyDefaults(spec v1.TracingConfigSpec, defaults []v1.TracingDefaults) (v1.TracingConfigSpec, map[string]string) {
+	var resolved v1.TracingConfigSpec
+	spec.DeepCopyInto(&resolved)
+	origins := map[string]string{}
+
+	ordered := make([]*v1.TracingDefaults, len(defaults))
+	for i := range defaults {
+		ordered[i] = &defaults[i]
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Name < ordered[j].Name })
+
+	setString := func(field string, value *string, original, fallback, source string) {
+		if original == "" && fallback != "" {
+			*value = fallback
+			origins[field] = source
+		}
+	}
+	for _, item := range ordered {
+		d := &item.Spec
+		setString("spec.endpoint", &resolved.Endpoint, spec.Endpoint, d.Endpoint, item.Name)
+		setString("spec.protocol", &resolved.Protocol, spec.Protocol, d.Protocol, item.Name)
+		setString("spec.compression", &resolved.Compression, spec.Compression, d.Compression, item.Name)
+		setString("spec.exportTimeout", &resolved.ExportTimeout, spec.ExportTimeout, d.ExportTimeout, item.Name)
+		setString("spec.batchTimeout", &resolved.BatchTimeout, spec.BatchTimeout, d.BatchTimeout, item.Name)
+		if spec.MaxBatchSize == 0 && d.MaxBatchSize > 0 {
+			resolved.MaxBatchSize = d.MaxBatchSize
+			origins["spec.maxBatchSize"] = item.Name
+		}
+		if spec.TLS == nil && d.TLS != nil {
+			tls := *d.TLS
+			resolved.TLS = &tls
+			origins["spec.tls"] = item.Name
+		}
+		resolved.Headers = mergeDefaults(resolved.Headers, spec.Headers, d.Headers, "spec.headers.", item.Name, origins)
+		resolved.Attributes = mergeDefaults(resolved.Attributes, spec.Attributes, d.Attributes, "spec.attributes.", item.Name, origins)
+	}
+	return resolved, origins
+}
+
+// DefaultedFields lists the origins ApplyDefaults returns the way they are reported in status,
+// sorted by field
+func DefaultedFields(origins map[string]string) []v1.DefaultedField {
+	var fields []v1.DefaultedField
+	for field, source := range origins {
+		fields = append(fields, v1.DefaultedField{Field: field, Defaults: source})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// mergeDefaults adds the default entries whose keys the original map does not set, recording
+// each under prefix+key in origins
+func mergeDefaults(resolved, original, defaults map[string]string, prefix, source string, origins map[string]string) map[string]string {
+	for key, value := range defaults {
+		if _, ok := original[key]; ok {
+			continue
+		}
+		if resolved == nil {
+			resolved = map[string]string{}
+		}
+		resolved[key] = value
+		origins[prefix+key] = source
+	}
+	return resolved
+}
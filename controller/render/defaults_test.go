@@ -0,0 +1,106 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	defaults := func(name string, spec v1.TracingDefaultsSpec) v1.TracingDefaults {
+		return v1.TracingDefaults{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+	}
+	cluster := defaults("a-cluster", v1.TracingDefaultsSpec{
+		Endpoint:     "collector:4317",
+		Protocol:     "grpc",
+		MaxBatchSize: 512,
+		Headers:      map[string]string{"x-tenant": "shop", "x-api-key": "cluster"},
+	})
+	team := defaults("b-team", v1.TracingDefaultsSpec{
+		Endpoint: "gateway:4318",
+		Protocol: "http/protobuf",
+		TLS:      &v1.TracingTLSConfig{CASecretName: "gateway-ca"},
+		Headers:  map[string]string{"x-api-key": "team"},
+	})
+
+	tests := []struct {
+		name     string
+		spec     v1.TracingConfigSpec
+		defaults []v1.TracingDefaults
+		want     v1.TracingConfigSpec
+		origins  map[string]string
+	}{
+		{
+			name:    "no defaults",
+			spec:    v1.TracingConfigSpec{Endpoint: "own:4317"},
+			want:    v1.TracingConfigSpec{Endpoint: "own:4317"},
+			origins: map[string]string{},
+		},
+		{
+			name:     "unset fields are filled",
+			spec:     v1.TracingConfigSpec{ServiceName: "checkout"},
+			defaults: []v1.TracingDefaults{cluster},
+			want: v1.TracingConfigSpec{ServiceName: "checkout", Endpoint: "collector:4317", Protocol: "grpc", MaxBatchSize: 512,
+				Headers: map[string]string{"x-tenant": "shop", "x-api-key": "cluster"}},
+			origins: map[string]string{"spec.endpoint": "a-cluster", "spec.protocol": "a-cluster", "spec.maxBatchSize": "a-cluster",
+				"spec.headers.x-tenant": "a-cluster", "spec.headers.x-api-key": "a-cluster"},
+		},
+		{
+			name:     "spec wins",
+			spec:     v1.TracingConfigSpec{Endpoint: "own:4317", MaxBatchSize: 64, Headers: map[string]string{"x-api-key": "own"}},
+			defaults: []v1.TracingDefaults{cluster},
+			want: v1.TracingConfigSpec{Endpoint: "own:4317", Protocol: "grpc", MaxBatchSize: 64,
+				Headers: map[string]string{"x-tenant": "shop", "x-api-key": "own"}},
+			origins: map[string]string{"spec.protocol": "a-cluster", "spec.headers.x-tenant": "a-cluster"},
+		},
+		{
+			name:     "later defaults win, in name order",
+			defaults: []v1.TracingDefaults{team, cluster},
+			want: v1.TracingConfigSpec{Endpoint: "gateway:4318", Protocol: "http/protobuf", MaxBatchSize: 512,
+				TLS:     &v1.TracingTLSConfig{CASecretName: "gateway-ca"},
+				Headers: map[string]string{"x-tenant": "shop", "x-api-key": "team"}},
+			origins: map[string]string{"spec.endpoint": "b-team", "spec.protocol": "b-team", "spec.maxBatchSize": "a-cluster", "spec.tls": "b-team",
+				"spec.headers.x-tenant": "a-cluster", "spec.headers.x-api-key": "b-team"},
+		},
+		{
+			name:     "own TLS is kept whole",
+			spec:     v1.TracingConfigSpec{TLS: &v1.TracingTLSConfig{Insecure: true}},
+			defaults: []v1.TracingDefaults{team},
+			want: v1.TracingConfigSpec{Endpoint: "gateway:4318", Protocol: "http/protobuf",
+				TLS:     &v1.TracingTLSConfig{Insecure: true},
+				Headers: map[string]string{"x-api-key": "team"}},
+			origins: map[string]string{"spec.endpoint": "b-team", "spec.protocol": "b-team", "spec.headers.x-api-key": "b-team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var original v1.TracingConfigSpec
+			tt.spec.DeepCopyInto(&original)
+
+			resolved, origins := ApplyDefaults(tt.spec, tt.defaults)
+			if !reflect.DeepEqual(resolved, tt.want) {
+				t.Errorf("ApplyDefaults() = %+v, want %+v", resolved, tt.want)
+			}
+			if !reflect.DeepEqual(origins, tt.origins) {
+				t.Errorf("origins = %v, want %v", origins, tt.origins)
+			}
+			if !reflect.DeepEqual(tt.spec, original) {
+				t.Errorf("ApplyDefaults() changed its input to %+v", tt.spec)
+			}
+		})
+	}
+}
+
+func TestDefaultedFields(t *testing.T) {
+	fields := DefaultedFields(map[string]string{"spec.protocol": "b-team", "spec.endpoint": "a-cluster"})
+	want := []v1.DefaultedField{{Field: "spec.endpoint", Defaults: "a-cluster"}, {Field: "spec.protocol", Defaults: "b-team"}}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("DefaultedFields() = %v, want %v", fields, want)
+	}
+	if fields := DefaultedFields(map[string]string{}); fields != nil {
+		t.Errorf("DefaultedFields() = %v, want nothing to report", fields)
+	}
+}
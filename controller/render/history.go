@@ -0,0 +1,64 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// RevisionSpec encodes the spec recorded in a revision. RollbackTo is never recorded, so that
+// rolling back to a revision yields the same encoding.
+func RevisionSpec(spec *v1.TracingConfigSpec) ([]byte, error) {
+	var recorded v1.TracingConfigSpec
+	spec.DeepCopyInto(&recorded)
+	recorded.RollbackTo = nil
+	return json.Marshal(&recorded)
+}
+
+// SpecRevisionName returns the name of the ControllerRevision recording an encoded spec
+func SpecRevisionName(tracingConfig *v1.TracingConfig, encoded []byte) string {
+	hash := sha256.Sum256(encoded)
+	return fmt.Sprintf("%s-%s", tracingConfig.Name, hex.EncodeToString(hash[:])[:10])
+}
+
+// SpecFromRevision decodes the spec recorded in a revision
+func SpecFromRevision(revision *appsv1.ControllerRevision) (v1.TracingConfigSpec, error) {
+	var spec v1.TracingConfigSpec
+	if err := json.Unmarshal(revision.Data.Raw, &spec); err != nil {
+		return spec, fmt.Errorf("revision %d of %s is not a TracingConfig spec: %w", revision.Revision, revision.Name, err)
+	}
+	return spec, nil
+}
+
+// OwnedRevisions returns the revisions recorded for the TracingConfig, oldest first
+func OwnedRevisions(tracingConfig *v1.TracingConfig, revisions []appsv1.ControllerRevision) []*appsv1.ControllerRevision {
+	var owned []*appsv1.ControllerRevision
+	for i := range revisions {
+		for _, owner := range revisions[i].OwnerReferences {
+			if owner.UID == tracingConfig.UID {
+				owned = append(owned, &revisions[i])
+				break
+			}
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return owned[i].Revision < owned[j].Revision })
+	return owned
+}
+
+// RollbackTarget finds the revision to roll back to among revisions, sorted oldest first:
+// the given number, or for 0 the newest one before the current revision
+func RollbackTarget(revisions []*appsv1.ControllerRevision, current, number int64) *appsv1.ControllerRevision {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		if number == 0 && revision.Revision < current || number != 0 && revision.Revision == number {
+			return revision
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	// Revisions 1 and 4 were pruned
+	var revisions []*appsv1.ControllerRevision
+	for _, number := range []int64{2, 3, 5} {
+		revisions = append(revisions, &appsv1.ControllerRevision{Revision: number})
+	}
+
+	tests := []struct {
+		name            string
+		current, number int64
+		want            int64
+	}{
+		{name: "previous revision", current: 5, want: 3},
+		{name: "previous revision was pruned", current: 3, want: 2},
+		{name: "named revision", current: 5, number: 2, want: 2},
+		{name: "current revision", current: 5, number: 5, want: 5},
+		{name: "pruned revision", current: 5, number: 4},
+		{name: "oldest revision was pruned", current: 5, number: 1},
+		{name: "nothing before the oldest", current: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := RollbackTarget(revisions, tt.current, tt.number)
+			var got int64
+			if target != nil {
+				got = target.Revision
+			}
+			if got != tt.want {
+				t.Errorf("RollbackTarget(%d, %d) = revision %d, want %d", tt.current, tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevisionSpecIgnoresRollbackTo(t *testing.T) {
+	tracingConfig := &v1.TracingConfig{ObjectMeta: metav1.ObjectMeta{Name: "checkout"}}
+	spec := v1.TracingConfigSpec{ServiceName: "checkout", SamplingRate: 0.1}
+	requested := spec
+	requested.RollbackTo = &v1.RollbackConfig{Revision: 2}
+
+	encoded, err := RevisionSpec(&spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedRequest, err := RevisionSpec(&requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if SpecRevisionName(tracingConfig, encoded) != SpecRevisionName(tracingConfig, encodedRequest) {
+		t.Errorf("a pending rollback changed the revision name")
+	}
+
+	decoded, err := SpecFromRevision(&appsv1.ControllerRevision{Data: runtime.RawExtension{Raw: encodedRequest}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.RollbackTo != nil || decoded.SamplingRate != 0.1 {
+		t.Errorf("SpecFromRevision() = %+v, want the spec without rollbackTo", decoded)
+	}
+}
@@ -0,0 +1,160 @@
+package render
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// PolicyEvaluation is the outcome of checking a spec against TracingPolicies
+type PolicyEvaluation struct {
+	// Spec is the spec with its sampling rates lowered to the caps of the clamping policies
+	Spec v1.TracingConfigSpec
+	// MaxSamplingRate is the lowest cap of the policies, nil when none sets one
+	MaxSamplingRate *float64
+	Violations      []v1.PolicyViolation
+}
+
+// Rejected reports whether a violation keeps the spec from being rendered
+func (e PolicyEvaluation) Rejected() bool {
+	return len(e.RejectedViolations()) > 0
+}
+
+// RejectedViolations returns the violations that could not be clamped
+func (e PolicyEvaluation) RejectedViolations() []v1.PolicyViolation {
+	var rejected []v1.PolicyViolation
+	for _, violation := range e.Violations {
+		if violation.Outcome == v1.PolicyViolationRejected {
+			rejected = append(rejected, violation)
+		}
+	}
+	return rejected
+}
+
+// EvaluatePolicies checks spec against the policies, which must all apply to it. Every policy
+// sees the spec as written, so that the outcome does not depend on the order they are checked in.
+func EvaluatePolicies(spec v1.TracingConfigSpec, policies []v1.TracingPolicy) PolicyEvaluation {
+	sorted := make([]v1.TracingPolicy, len(policies))
+	copy(sorted, policies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var evaluation PolicyEvaluation
+	spec.DeepCopyInto(&evaluation.Spec)
+	clamped := &evaluation.Spec
+
+	for _, policy := range sorted {
+		violate := func(field, message, outcome string) {
+			evaluation.Violations = append(evaluation.Violations, v1.PolicyViolation{
+				Policy:  policy.Name,
+				Field:   field,
+				Message: message,
+				Outcome: outcome,
+			})
+		}
+
+		if limit := policy.Spec.MaxSamplingRate; limit != nil {
+			if evaluation.MaxSamplingRate == nil || *limit < *evaluation.MaxSamplingRate {
+				evaluation.MaxSamplingRate = new(float64)
+				*evaluation.MaxSamplingRate = *limit
+			}
+			outcome := v1.PolicyViolationClamped
+			if policy.Spec.Action == v1.PolicyActionReject {
+				outcome = v1.PolicyViolationRejected
+			}
+			capRate := func(field string, rate float64, clampedRate *float64) {
+				if rate <= *limit {
+					return
+				}
+				violate(field, fmt.Sprintf("sampling rate %s exceeds the cap of %s", FormatSamplingRate(rate), FormatSamplingRate(*limit)), outcome)
+				if outcome == v1.PolicyViolationClamped && *clampedRate > *limit {
+					*clampedRate = *limit
+				}
+			}
+
+			capRate("spec.samplingRate", spec.SamplingRate, &clamped.SamplingRate)
+			if spec.Schedule != nil {
+				for i, window := range spec.Schedule.Windows {
+					if window.SamplingRate != nil {
+						capRate(fmt.Sprintf("spec.schedule.windows[%d].samplingRate", i), *window.SamplingRate, clamped.Schedule.Windows[i].SamplingRate)
+					}
+				}
+			}
+		}
+
+		if len(policy.Spec.AllowedEndpoints) > 0 && spec.Endpoint != "" && !EndpointAllowed(spec.Endpoint, policy.Spec.AllowedEndpoints) {
+			violate("spec.endpoint", fmt.Sprintf("endpoint %s is not in the allow-list", spec.Endpoint), v1.PolicyViolationRejected)
+		}
+	}
+	return evaluation
+}
+
+// DebugSessionViolations checks the sampling rate of a debug session against the caps of the
+// policies. A Clamp policy lowers the session's rate to its cap when it is applied.
+func DebugSessionViolations(session *v1.TracingDebugSession, policies []v1.TracingPolicy) []v1.PolicyViolation {
+	sorted := make([]v1.TracingPolicy, len(policies))
+	copy(sorted, policies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	rate := session.EffectiveSamplingRate()
+	var violations []v1.PolicyViolation
+	for _, policy := range sorted {
+		limit := policy.Spec.MaxSamplingRate
+		if limit == nil || rate <= *limit {
+			continue
+		}
+		outcome := v1.PolicyViolationClamped
+		if policy.Spec.Action == v1.PolicyActionReject {
+			outcome = v1.PolicyViolationRejected
+		}
+		violations = append(violations, v1.PolicyViolation{
+			Policy:  policy.Name,
+			Field:   "spec.samplingRate",
+			Message: fmt.Sprintf("sampling rate %s exceeds the cap of %s", FormatSamplingRate(rate), FormatSamplingRate(*limit)),
+			Outcome: outcome,
+		})
+	}
+	return violations
+}
+
+// EndpointAllowed reports whether an endpoint, host:port or a URL, matches one of the patterns.
+// A pattern without a port matches the host on any port.
+func EndpointAllowed(endpoint string, patterns []string) bool {
+	host := endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+len("://"):]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	hostname := host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		hostname = name
+	}
+
+	for _, pattern := range patterns {
+		// An IPv6 pattern such as [::1]:4317 would read as a character class to path.Match
+		if pattern == host || pattern == hostname {
+			return true
+		}
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, hostname); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeViolations lists the violations for messages
+func DescribeViolations(violations []v1.PolicyViolation) string {
+	descriptions := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s (TracingPolicy %s)", violation.Field, violation.Message, violation.Policy))
+	}
+	return strings.Join(descriptions, "; ")
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestEvaluatePoliciesMessagePrecision(t *testing.T) {
+	limit := 0.5
+	policies := []v1.TracingPolicy{{Spec: v1.TracingPolicySpec{MaxSamplingRate: &limit}}}
+	policies[0].Name = "cap"
+
+	evaluation := EvaluatePolicies(v1.TracingConfigSpec{SamplingRate: 0.505}, policies)
+	if len(evaluation.Violations) != 1 {
+		t.Fatalf("violations = %v, want one", evaluation.Violations)
+	}
+	if want := "sampling rate 0.505 exceeds the cap of 0.5"; evaluation.Violations[0].Message != want {
+		t.Errorf("message = %q, want %q", evaluation.Violations[0].Message, want)
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	low, high := 0.1, 0.5
+	policy := func(name string, spec v1.TracingPolicySpec) v1.TracingPolicy {
+		p := v1.TracingPolicy{Spec: spec}
+		p.Name = name
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		spec      v1.TracingConfigSpec
+		policies  []v1.TracingPolicy
+		wantRate  float64
+		wantMax   *float64
+		violation []string
+		rejected  bool
+	}{
+		{
+			name:     "within the cap",
+			spec:     v1.TracingConfigSpec{SamplingRate: 0.1},
+			policies: []v1.TracingPolicy{policy("cap", v1.TracingPolicySpec{MaxSamplingRate: &high})},
+			wantRate: 0.1,
+			wantMax:  &high,
+		},
+		{
+			name:      "clamped to the lowest cap",
+			spec:      v1.TracingConfigSpec{SamplingRate: 1},
+			policies:  []v1.TracingPolicy{policy("b-high", v1.TracingPolicySpec{MaxSamplingRate: &high}), policy("a-low", v1.TracingPolicySpec{MaxSamplingRate: &low})},
+			wantRate:  0.1,
+			wantMax:   &low,
+			violation: []string{"a-low/Clamped", "b-high/Clamped"},
+		},
+		{
+			name:      "rejected",
+			spec:      v1.TracingConfigSpec{SamplingRate: 1},
+			policies:  []v1.TracingPolicy{policy("cap", v1.TracingPolicySpec{MaxSamplingRate: &high, Action: v1.PolicyActionReject})},
+			wantRate:  1,
+			wantMax:   &high,
+			violation: []string{"cap/Rejected"},
+			rejected:  true,
+		},
+		{
+			name:     "endpoint allowed",
+			spec:     v1.TracingConfigSpec{Endpoint: "https://collector.observability:4318/otlp"},
+			policies: []v1.TracingPolicy{policy("endpoints", v1.TracingPolicySpec{AllowedEndpoints: []string{"*.observability"}})},
+		},
+		{
+			name:      "endpoint not allowed",
+			spec:      v1.TracingConfigSpec{Endpoint: "collector.elsewhere:4317"},
+			policies:  []v1.TracingPolicy{policy("endpoints", v1.TracingPolicySpec{AllowedEndpoints: []string{"*.observability"}})},
+			violation: []string{"endpoints/Rejected"},
+			rejected:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := EvaluatePolicies(tt.spec, tt.policies)
+			if evaluation.Spec.SamplingRate != tt.wantRate {
+				t.Errorf("sampling rate = %v, want %v", evaluation.Spec.SamplingRate, tt.wantRate)
+			}
+			if (evaluation.MaxSamplingRate == nil) != (tt.wantMax == nil) || (tt.wantMax != nil && *evaluation.MaxSamplingRate != *tt.wantMax) {
+				t.Errorf("max sampling rate = %v, want %v", evaluation.MaxSamplingRate, tt.wantMax)
+			}
+			var violations []string
+			for _, violation := range evaluation.Violations {
+				violations = append(violations, violation.Policy+"/"+violation.Outcome)
+			}
+			if strings.Join(violations, ",") != strings.Join(tt.violation, ",") {
+				t.Errorf("violations = %v, want %v", violations, tt.violation)
+			}
+			if evaluation.Rejected() != tt.rejected {
+				t.Errorf("Rejected() = %v, want %v", evaluation.Rejected(), tt.rejected)
+			}
+		})
+	}
+}
+
+func TestEndpointAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		patterns []string
+		want     bool
+	}{
+		{name: "host:port on any port", endpoint: "collector:4317", patterns: []string{"collector"}, want: true},
+		{name: "host:port exact", endpoint: "collector:4317", patterns: []string{"collector:4317"}, want: true},
+		{name: "other port", endpoint: "collector:4318", patterns: []string{"collector:4317"}, want: false},
+		{name: "wildcard", endpoint: "otel.observability:4317", patterns: []string{"*.observability"}, want: true},
+		{name: "other host", endpoint: "otel.elsewhere:4317", patterns: []string{"*.observability"}, want: false},
+		{name: "scheme", endpoint: "https://collector:4318", patterns: []string{"collector:4318"}, want: true},
+		{name: "scheme without port", endpoint: "https://collector", patterns: []string{"collector"}, want: true},
+		{name: "path", endpoint: "collector:4318/otlp/v1/traces", patterns: []string{"collector"}, want: true},
+		{name: "scheme, path and query", endpoint: "http://collector:4318/otlp?x=1", patterns: []string{"collector:4318"}, want: true},
+		{name: "path does not widen the match", endpoint: "http://evil/collector", patterns: []string{"collector"}, want: false},
+		{name: "IPv6 on any port", endpoint: "[::1]:4317", patterns: []string{"::1"}, want: true},
+		{name: "IPv6 exact", endpoint: "[::1]:4317", patterns: []string{"[::1]:4317"}, want: true},
+		{name: "IPv6 URL", endpoint: "http://[fd00::1]:4318/otlp", patterns: []string{"fd00::1"}, want: true},
+		{name: "IPv6 other port", endpoint: "[::1]:4318", patterns: []string{"[::1]:4317"}, want: false},
+		{name: "IPv6 other host", endpoint: "[::2]:4317", patterns: []string{"::1"}, want: false},
+		{name: "no patterns", endpoint: "collector:4317", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EndpointAllowed(tt.endpoint, tt.patterns); got != tt.want {
+				t.Errorf("EndpointAllowed(%q, %v) = %v, want %v", tt.endpoint, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
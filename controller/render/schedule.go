@@ -0,0 +1,100 @@
+package render
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // the controller image does not ship a zoneinfo database
+
+	"github.com/robfig/cron/v3"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// ActiveSchedule is the outcome of evaluating a TracingSchedule at a point in time
+type ActiveSchedule struct {
+	// Window is the active window, nil when the base configuration applies
+	Window *v1.SamplingWindow
+	// NextTransition is the next time a window opens or closes, zero if there is none
+	NextTransition time.Time
+}
+
+// EvaluateSchedule finds the window active at now and the next window boundary. When several
+// windows are active at once, the first one in the list wins.
+func EvaluateSchedule(schedule *v1.TracingSchedule, now time.Time) (ActiveSchedule, error) {
+	var result ActiveSchedule
+	if schedule == nil || len(schedule.Windows) == 0 {
+		return result, nil
+	}
+
+	location := time.UTC
+	if schedule.TimeZone != "" {
+		loc, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return result, fmt.Errorf("invalid timeZone %q: %w", schedule.TimeZone, err)
+		}
+		location = loc
+	}
+	now = now.In(location)
+
+	for i := range schedule.Windows {
+		window := &schedule.Windows[i]
+
+		cronSchedule, err := cron.ParseStandard(window.Cron)
+		if err != nil {
+			return result, fmt.Errorf("window %q: invalid cron expression %q: %w", window.Name, window.Cron, err)
+		}
+		duration, err := time.ParseDuration(window.Duration)
+		if err != nil || duration <= 0 {
+			return result, fmt.Errorf("window %q: invalid duration %q", window.Name, window.Duration)
+		}
+
+		open, boundary := windowBoundary(cronSchedule, duration, now)
+		if open && result.Window == nil {
+			result.Window = window
+		}
+
+		if !boundary.IsZero() && (result.NextTransition.IsZero() || boundary.Before(result.NextTransition)) {
+			result.NextTransition = boundary
+		}
+	}
+	return result, nil
+}
+
+// maxOverlappingActivations bounds the walk over back-to-back activations; a window whose
+// duration is longer than its period never closes
+const maxOverlappingActivations = 1000
+
+// windowBoundary reports whether a window is open at now and when that next changes: when it
+// closes, after the last of any overlapping activations, or else when it next opens
+func windowBoundary(schedule cron.Schedule, duration time.Duration, now time.Time) (bool, time.Time) {
+	// The window is open if it started within the last duration
+	start := schedule.Next(now.Add(-duration))
+	if start.IsZero() || start.After(now) {
+		return false, schedule.Next(now)
+	}
+
+	// An activation starting before the window closes keeps it open
+	end := start.Add(duration)
+	for i := 0; i < maxOverlappingActivations; i++ {
+		next := schedule.Next(start)
+		if next.IsZero() || next.After(end) {
+			return true, end
+		}
+		start, end = next, next.Add(duration)
+	}
+	return true, time.Time{}
+}
+
+// ApplySchedule returns the spec with the active window's overrides applied
+func ApplySchedule(spec v1.TracingConfigSpec, active ActiveSchedule) v1.TracingConfigSpec {
+	if active.Window == nil {
+		return spec
+	}
+	if active.Window.SamplingRate != nil {
+		spec.SamplingRate = *active.Window.SamplingRate
+	}
+	if active.Window.Enabled != nil {
+		spec.Enabled = *active.Window.Enabled
+	}
+	return spec
+}
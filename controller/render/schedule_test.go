@@ -0,0 +1,130 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	v1 "tracing-controller/api/v1"
+)
+
+func TestEvaluateSchedule(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	window := func(name, cron, duration string) v1.SamplingWindow {
+		return v1.SamplingWindow{Name: name, Cron: cron, Duration: duration}
+	}
+
+	tests := []struct {
+		name     string
+		schedule v1.TracingSchedule
+		now      string
+		active   string
+		next     string
+	}{
+		{
+			name:     "before the window",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("business", "0 9 * * *", "8h")}},
+			now:      "2026-03-10T08:00:00Z", next: "2026-03-10T09:00:00Z",
+		},
+		{
+			name:     "inside the window",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("business", "0 9 * * *", "8h")}},
+			now:      "2026-03-10T12:00:00Z", active: "business", next: "2026-03-10T17:00:00Z",
+		},
+		{
+			name:     "window just closed",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("business", "0 9 * * *", "8h")}},
+			now:      "2026-03-10T17:00:00Z", next: "2026-03-11T09:00:00Z",
+		},
+		{
+			name:     "across midnight",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("batch", "0 23 * * *", "2h")}},
+			now:      "2026-03-11T00:30:00Z", active: "batch", next: "2026-03-11T01:00:00Z",
+		},
+		{
+			name:     "overlapping activations",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("lunch", "0 12,13 * * *", "90m")}},
+			now:      "2026-03-10T12:45:00Z", active: "lunch", next: "2026-03-10T14:30:00Z",
+		},
+		{
+			name:     "never closes",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("hourly", "0 * * * *", "90m")}},
+			now:      "2026-03-10T12:45:00Z", active: "hourly",
+		},
+		{
+			name:     "activations back to back",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{window("weekdays", "0 0 * * 1-5", "24h")}},
+			now:      "2026-03-10T12:00:00Z", active: "weekdays", next: "2026-03-14T00:00:00Z",
+		},
+		{
+			name: "overlapping windows, first wins",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{
+				window("morning", "0 8 * * *", "4h"),
+				window("release", "0 10 * * *", "4h"),
+			}},
+			now: "2026-03-10T11:00:00Z", active: "morning", next: "2026-03-10T12:00:00Z",
+		},
+		{
+			name: "second window outlasts the first",
+			schedule: v1.TracingSchedule{Windows: []v1.SamplingWindow{
+				window("morning", "0 8 * * *", "4h"),
+				window("release", "0 10 * * *", "4h"),
+			}},
+			now: "2026-03-10T12:00:00Z", active: "release", next: "2026-03-10T14:00:00Z",
+		},
+		{
+			// Europe/Berlin springs forward at 02:00 CET; the window still lasts two real hours
+			name: "across DST",
+			schedule: v1.TracingSchedule{TimeZone: "Europe/Berlin", Windows: []v1.SamplingWindow{
+				window("night", "0 1 * * *", "2h"),
+			}},
+			now: "2026-03-29T01:30:00Z", active: "night", next: "2026-03-29T02:00:00Z",
+		},
+		{
+			name: "opens after DST",
+			schedule: v1.TracingSchedule{TimeZone: "Europe/Berlin", Windows: []v1.SamplingWindow{
+				window("business", "0 9 * * *", "8h"),
+			}},
+			now: "2026-03-29T06:00:00Z", next: "2026-03-29T07:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvaluateSchedule(&tt.schedule, at(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			active := ""
+			if result.Window != nil {
+				active = result.Window.Name
+			}
+			if active != tt.active {
+				t.Errorf("active window = %q, want %q", active, tt.active)
+			}
+			var want time.Time
+			if tt.next != "" {
+				want = at(tt.next)
+			}
+			if !result.NextTransition.Equal(want) {
+				t.Errorf("next transition = %s, want %s", result.NextTransition.UTC().Format(time.RFC3339), tt.next)
+			}
+		})
+	}
+}
+
+func TestEvaluateScheduleInvalid(t *testing.T) {
+	for _, schedule := range []v1.TracingSchedule{
+		{TimeZone: "Mars/Olympus", Windows: []v1.SamplingWindow{{Name: "w", Cron: "0 9 * * *", Duration: "1h"}}},
+		{Windows: []v1.SamplingWindow{{Name: "w", Cron: "at nine", Duration: "1h"}}},
+		{Windows: []v1.SamplingWindow{{Name: "w", Cron: "0 9 * * *", Duration: "0s"}}},
+	} {
+		if _, err := EvaluateSchedule(&schedule, time.Now()); err == nil {
+			t.Errorf("EvaluateSchedule(%+v) succeeded, want an error", schedule)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	v1 "tracing-controller/api/v1"
+)
+
+// HeadersEnv carries the exporter headers, which often hold API keys
+const HeadersEnv = "OTEL_EXPORTER_OTLP_HEADERS"
+
+// SecretName returns the name of the Secret rendered for a TracingConfig in every target namespace
+func SecretName(tracingConfig *v1.TracingConfig) string {
+	return fmt.Sprintf("%s-tracing-secret", tracingConfig.Name)
+}
+
+// SecretData renders the sensitive OTEL_* variables, kept out of the ConfigMap: the spec's
+// headers merged with those read from the headers Secret, which win. It returns nil when there
+// is nothing sensitive to render.
+func SecretData(spec *v1.TracingConfigSpec, secretHeaders map[string]string) map[string]string {
+	headers := make(map[string]string, len(spec.Headers)+len(secretHeaders))
+	for key, value := range spec.Headers {
+		headers[key] = value
+	}
+	for key, value := range secretHeaders {
+		headers[key] = value
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(headers[key]))
+	}
+	return map[string]string{HeadersEnv: strings.Join(pairs, ",")}
+}
+
+// SecretKeys returns the keys the spec renders into the Secret, without reading the headers Secret
+func SecretKeys(spec *v1.TracingConfigSpec) []string {
+	if len(spec.Headers) == 0 && spec.HeadersSecretName == "" {
+		return nil
+	}
+	return []string{HeadersEnv}
+}
+
+// WithSecretKeys returns a copy of data in which the keys rendered into the Secret point at it.
+// Their values are described rather than resolved, so that they can be shown in status.
+func WithSecretKeys(data map[string]string, secretName string, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return data
+	}
+	merged := make(map[string]string, len(data)+len(keys))
+	for key, value := range data {
+		merged[key] = value
+	}
+	for _, key := range keys {
+		merged[key] = fmt.Sprintf("<secret %s key %s>", secretName, key)
+	}
+	return merged
+}
+
+// WithSecretDigest adds a digest of the Secrets the workloads read from to data, so that the
+// revision hash changes, and the pods roll, when one of them is rotated
+func WithSecretDigest(data map[string]string, digest string) map[string]string {
+	if digest == "" {
+		return data
+	}
+	merged := make(map[string]string, len(data)+1)
+	for key, value := range data {
+		merged[key] = value
+	}
+	merged["secretDigest"] = digest
+	return merged
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	v1 "tracing-controller/api/v1"
+)
+
+const (
+	// SkipReasonOptedOut and SkipReasonAssignedElsewhere say why a workload the TracingConfig
+	// would otherwise inject is left alone
+	SkipReasonOptedOut          = "OptedOut"
+	SkipReasonAssignedElsewhere = "AssignedToOtherConfig"
+)
+
+// SelectWorkload decides whether the TracingConfig injects the deployment. The opt-out and
+// opt-in annotations are read from the deployment and from its pod template. When the
+// TracingConfig would have injected the deployment but the annotations say otherwise, the
+// returned SkippedWorkload tells why.
+func SelectWorkload(tracingConfig *v1.TracingConfig, selector labels.Selector, deployment *appsv1.Deployment) (bool, *v1.SkippedWorkload) {
+	assigned := workloadAnnotation(deployment, v1.AnnotationConfig)
+	claimed := assigned == tracingConfig.Namespace+"/"+tracingConfig.Name ||
+		(assigned == tracingConfig.Name && deployment.Namespace == tracingConfig.Namespace)
+	matched := selector.Matches(labels.Set(deployment.Labels))
+	if !claimed && !matched {
+		return false, nil
+	}
+
+	skip := &v1.SkippedWorkload{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name}
+	switch {
+	case workloadAnnotation(deployment, v1.AnnotationInject) == "false":
+		skip.Reason = SkipReasonOptedOut
+		skip.Message = v1.AnnotationInject + " is false"
+		return false, skip
+	case assigned != "" && !claimed:
+		skip.Reason = SkipReasonAssignedElsewhere
+		skip.Message = v1.AnnotationConfig + " names " + assigned
+		return false, skip
+	}
+	return true, nil
+}
+
+// workloadAnnotation reads an annotation from the pod template, falling back to the deployment
+func workloadAnnotation(deployment *appsv1.Deployment, key string) string {
+	if value, ok := deployment.Spec.Template.Annotations[key]; ok {
+		return value
+	}
+	return deployment.Annotations[key]
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// ServiceNameData is what a serviceName template can refer to
+type ServiceNameData struct {
+	Workload    WorkloadReference
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// WorkloadReference identifies the workload a service name is rendered for
+type WorkloadReference struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// mapIndex matches the .Labels["key"] shorthand, which text/template itself does not support
+var mapIndex = regexp.MustCompile(`\.(Labels|Annotations)\["([^"]*)"\]`)
+
+// IsServiceNameTemplate reports whether the service name has to be rendered per workload
+func IsServiceNameTemplate(serviceName string) bool {
+	return strings.Contains(serviceName, "{{")
+}
+
+// ParseServiceName parses a serviceName template. Besides the usual text/template syntax,
+// .Labels["key"] and .Annotations["key"] are accepted as shorthand for index.
+func ParseServiceName(serviceName string) (*template.Template, error) {
+	text := mapIndex.ReplaceAllString(serviceName, `(index .$1 "$2")`)
+	tmpl, err := template.New("serviceName").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serviceName template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ServiceName renders the service name of a deployment. Names without a template are returned
+// as they are.
+func ServiceName(serviceName string, deployment *appsv1.Deployment) (string, error) {
+	if !IsServiceNameTemplate(serviceName) {
+		return serviceName, nil
+	}
+	tmpl, err := ParseServiceName(serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, ServiceNameData{
+		Workload:    WorkloadReference{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name},
+		Labels:      deployment.Labels,
+		Annotations: deployment.Annotations,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render serviceName: %w", err)
+	}
+	name := strings.TrimSpace(out.String())
+	if name == "" {
+		return "", fmt.Errorf("serviceName %q renders to an empty name for deployment %s", serviceName, deployment.Name)
+	}
+	return name, nil
+}
+
+// WithServiceName returns the rendered data with the service name of one workload
+func WithServiceName(data map[string]string, serviceName string) map[string]string {
+	if data["OTEL_SERVICE_NAME"] == serviceName {
+		return data
+	}
+	merged := make(map[string]string, len(data)+1)
+	for key, value := range data {
+		merged[key] = value
+	}
+	merged["OTEL_SERVICE_NAME"] = serviceName
+	return merged
+}
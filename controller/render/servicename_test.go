@@ -0,0 +1,81 @@
+package render
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceName(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "shop",
+		Name:        "checkout-v2",
+		Labels:      map[string]string{"app.kubernetes.io/name": "checkout", "team": "payments"},
+		Annotations: map[string]string{"tracing/service": "checkout-api"},
+	}}
+
+	tests := []struct {
+		name        string
+		serviceName string
+		want        string
+		wantErr     bool
+	}{
+		{name: "plain name", serviceName: "checkout", want: "checkout"},
+		{name: "workload name", serviceName: "{{ .Workload.Name }}", want: "checkout-v2"},
+		{name: "namespace and name", serviceName: "{{ .Workload.Namespace }}.{{ .Workload.Name }}", want: "shop.checkout-v2"},
+		{name: "label shorthand", serviceName: `{{ .Labels["app.kubernetes.io/name"] }}`, want: "checkout"},
+		{name: "annotation shorthand", serviceName: `{{ .Annotations["tracing/service"] }}`, want: "checkout-api"},
+		{name: "several shorthands", serviceName: `{{ .Labels["team"] }}-{{ .Labels["app.kubernetes.io/name"] }}`, want: "payments-checkout"},
+		{name: "index", serviceName: `{{ index .Labels "team" }}`, want: "payments"},
+		{name: "missing key with a fallback", serviceName: `{{ or .Labels["version"] .Workload.Name }}`, want: "checkout-v2"},
+		{name: "surrounding space is trimmed", serviceName: " {{ .Workload.Name }} ", want: "checkout-v2"},
+		{name: "missing key", serviceName: `{{ .Labels["version"] }}`, wantErr: true},
+		{name: "renders empty", serviceName: `{{ if false }}checkout{{ end }}`, wantErr: true},
+		{name: "unknown field", serviceName: "{{ .Pod.Name }}", wantErr: true},
+		{name: "invalid template", serviceName: "{{ .Workload.Name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ServiceName(tt.serviceName, deployment)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ServiceName(%q) = %q, want an error", tt.serviceName, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ServiceName(%q) = %q, %v, want %q", tt.serviceName, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceNameWithoutLabels(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout"}}
+	if got, err := ServiceName(`{{ or .Labels["app"] .Workload.Name }}`, deployment); err != nil || got != "checkout" {
+		t.Errorf("ServiceName() = %q, %v, want the workload name", got, err)
+	}
+	if got, err := ServiceName(`{{ .Annotations["service"] }}`, deployment); err == nil {
+		t.Errorf("ServiceName() = %q, want an error for an empty name", got)
+	}
+}
+
+func TestParseServiceName(t *testing.T) {
+	tests := []struct {
+		serviceName string
+		wantErr     bool
+	}{
+		{serviceName: `{{ .Labels["app"] }}`},
+		{serviceName: `{{ .Labels["app"] | printf "%s-svc" }}`},
+		{serviceName: `{{ .Labels["a"] }}{{ .Annotations["b"] }}`},
+		{serviceName: `{{ .Labels["app" }}`, wantErr: true},
+		{serviceName: "{{ end }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := ParseServiceName(tt.serviceName)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseServiceName(%q) = %v, want an error: %v", tt.serviceName, err, tt.wantErr)
+		}
+	}
+}
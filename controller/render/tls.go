@@ -0,0 +1,32 @@
+package render
+
+import (
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "tracing-controller/api/v1"
+)
+
+const (
+	// Where the CA bundle and client certificate Secrets are mounted in injected containers
+	TLSCAMountPath     = "/var/run/otel/tls/ca"
+	TLSClientMountPath = "/var/run/otel/tls/client"
+)
+
+// TLSEnv returns the OTEL_* variables describing transport security for the exporter
+func TLSEnv(tls *v1.TracingTLSConfig) map[string]string {
+	if tls == nil || tls.Insecure {
+		return map[string]string{"OTEL_EXPORTER_OTLP_INSECURE": "true"}
+	}
+
+	env := map[string]string{"OTEL_EXPORTER_OTLP_INSECURE": "false"}
+	if tls.CASecretName != "" {
+		env["OTEL_EXPORTER_OTLP_CERTIFICATE"] = path.Join(TLSCAMountPath, tls.CASecretKey())
+	}
+	if tls.ClientCertSecretName != "" {
+		env["OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"] = path.Join(TLSClientMountPath, corev1.TLSCertKey)
+		env["OTEL_EXPORTER_OTLP_CLIENT_KEY"] = path.Join(TLSClientMountPath, corev1.TLSPrivateKeyKey)
+	}
+	return env
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+const (
+	rolloutPhaseProgressing = "Progressing"
+	rolloutPhasePaused      = "Paused"
+	rolloutPhaseHalted      = "Halted"
+	rolloutPhaseComplete    = "Complete"
+
+	conditionRolledOut = "RolledOut"
+
+	// annotationRevision is stamped on the pod template with the revision the workload was moved to.
+	// Unlike the config hash it ignores debug overrides.
+	annotationRevision = "tracing.kubevishwa.io/revision"
+	// labelRevision marks the per-revision ConfigMaps rendered while a rollout strategy is set
+	labelRevision = "tracing.kubevishwa.io/revision"
+
+	// rolloutPollInterval is how often a batch is checked while its pods are rolling
+	rolloutPollInterval = 15 * time.Second
+)
+
+// rolloutPlan decides which workloads may move to the current revision in this reconcile
+type rolloutPlan struct {
+	revision string
+	budget   int
+	now      metav1.Time
+	status   tracingv1.RolloutStatus
+	// requeueAfter is when the rollout should be looked at again, zero once it is done
+	requeueAfter time.Duration
+}
+
+// planRollout looks at where the workloads of every target namespace stand and works out the
+// next batch. It returns nil when the TracingConfig has no rollout strategy.
+func (r *TracingConfigReconciler) planRollout(ctx context.Context, tracingConfig *tracingv1.TracingConfig, namespaces []string, selector labels.Selector, revision string, now time.Time) (plan *rolloutPlan, err error) {
+	strategy := tracingConfig.Spec.RolloutStrategy
+	if strategy == nil {
+		return nil, nil
+	}
+
+	ctx, span := startSpan(ctx, "PlanRollout")
+	defer func() { endSpan(span, err) }()
+
+	var pause time.Duration
+	if strategy.Pause != "" {
+		pause, err = time.ParseDuration(strategy.Pause)
+		if err != nil || pause < 0 {
+			return nil, terminal("InvalidRolloutStrategy", fmt.Errorf("invalid pause %q", strategy.Pause))
+		}
+	}
+	if strategy.BatchSize < 0 || strategy.BatchPercentage < 0 || strategy.BatchPercentage > 100 {
+		return nil, terminal("InvalidRolloutStrategy", fmt.Errorf("batchSize must be positive and batchPercentage between 1 and 100"))
+	}
+
+	plan = &rolloutPlan{
+		revision: revision,
+		now:      metav1.NewTime(now),
+		status:   tracingv1.RolloutStatus{Revision: revision},
+	}
+	// Pauses are counted between batches of the same revision; a new revision starts right away
+	if previous := tracingConfig.Status.Rollout; previous != nil && previous.Revision == revision {
+		plan.status.LastBatchAt = previous.LastBatchAt
+	}
+
+	var inFlight, crashLooping []string
+	for _, namespace := range namespaces {
+		deployments, _, err := r.selectDeployments(ctx, tracingConfig, namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		for i := range deployments {
+			deployment := &deployments[i]
+			plan.status.TotalWorkloads++
+			if deployment.Spec.Template.Annotations[annotationRevision] != revision {
+				continue
+			}
+			plan.status.UpdatedWorkloads++
+
+			if !deploymentRolledOut(deployment) {
+				inFlight = append(inFlight, namespace+"/"+deployment.Name)
+			}
+			if strategy.HaltOnCrashLoop {
+				crashing, err := r.hasCrashLoopingPods(ctx, deployment, revision)
+				if err != nil {
+					return nil, err
+				}
+				if crashing {
+					crashLooping = append(crashLooping, namespace+"/"+deployment.Name)
+				}
+			}
+		}
+	}
+
+	switch {
+	case len(crashLooping) > 0:
+		plan.status.Phase = rolloutPhaseHalted
+		plan.status.Message = fmt.Sprintf("Halted, pods are crash-looping in %s", strings.Join(crashLooping, ", "))
+		plan.requeueAfter = rolloutPollInterval
+	case plan.status.UpdatedWorkloads == plan.status.TotalWorkloads:
+		plan.status.Phase = rolloutPhaseComplete
+		plan.status.Message = fmt.Sprintf("All %d workloads run revision %s", plan.status.TotalWorkloads, revision)
+	case len(inFlight) > 0:
+		plan.status.Phase = rolloutPhaseProgressing
+		plan.status.Message = fmt.Sprintf("Waiting for %s to become available", strings.Join(inFlight, ", "))
+		plan.requeueAfter = rolloutPollInterval
+	case plan.status.LastBatchAt != nil && now.Before(plan.status.LastBatchAt.Add(pause)):
+		plan.status.Phase = rolloutPhasePaused
+		plan.status.Message = fmt.Sprintf("Pausing until %s", plan.status.LastBatchAt.Add(pause).Format(time.RFC3339))
+		plan.requeueAfter = plan.status.LastBatchAt.Add(pause).Sub(now) + time.Second
+	default:
+		plan.budget = strategy.BatchSizeFor(int(plan.status.TotalWorkloads))
+		plan.status.Phase = rolloutPhaseProgressing
+		plan.status.Message = fmt.Sprintf("Moving up to %d workloads to revision %s", plan.budget, revision)
+		plan.requeueAfter = rolloutPollInterval
+	}
+	return plan, nil
+}
+
+// admit reports whether the deployment may be moved to the plan's revision now. Workloads that
+// already run the revision are always admitted so that they keep being reconciled.
+func (p *rolloutPlan) admit(deployment *appsv1.Deployment) bool {
+	if p == nil || deployment.Spec.Template.Annotations[annotationRevision] == p.revision {
+		return true
+	}
+	if p.budget <= 0 {
+		return false
+	}
+	p.budget--
+	p.status.UpdatedWorkloads++
+	p.status.LastBatchAt = &p.now
+	return true
+}
+
+// deploymentRolledOut reports whether every replica of the deployment runs its current template
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas >= replicas &&
+		deployment.Status.AvailableReplicas >= replicas
+}
+
+// hasCrashLoopingPods reports whether a pod of the deployment running revision is in CrashLoopBackOff
+func (r *TracingConfigReconciler) hasCrashLoopingPods(ctx context.Context, deployment *appsv1.Deployment, revision string) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid selector on deployment %s: %w", deployment.Name, err)
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, fmt.Errorf("failed to list pods of deployment %s: %w", deployment.Name, err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Annotations[annotationRevision] != revision {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if waiting := containerStatus.State.Waiting; waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// pruneRevisions deletes the revision ConfigMaps of a namespace that no deployment refers to
+// anymore, except the current one
+func (r *TracingConfigReconciler) pruneRevisions(ctx context.Context, tracingConfig *tracingv1.TracingConfig, namespace, current string) error {
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, client.InNamespace(namespace),
+		client.MatchingLabels(configMapLabels(tracingConfig)), client.HasLabels{labelRevision}); err != nil {
+		return fmt.Errorf("failed to list revision ConfigMaps in %s: %w", namespace, err)
+	}
+	if len(configMaps.Items) == 0 {
+		return nil
+	}
+
+	var deployments appsv1.DeploymentList
+	if err := r.List(ctx, &deployments, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+	}
+	referenced := map[string]bool{}
+	for _, deployment := range deployments.Items {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.ConfigMapRef != nil {
+					referenced[envFrom.ConfigMapRef.Name] = true
+				}
+			}
+		}
+	}
+
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if configMap.Name == current || referenced[configMap.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+		}
+		log.Printf("Deleted unused ConfigMap revision %s/%s", namespace, configMap.Name)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+// rolloutDeployment is a deployment of the app selected by the TracingConfig. An empty revision
+// leaves it on the previous configuration; rolledOut says whether its pods caught up.
+func rolloutDeployment(name, revision string, rolledOut bool) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{"app": name}, Generation: 1},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}},
+		},
+		Status: appsv1.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	if revision != "" {
+		deployment.Spec.Template.Annotations[annotationRevision] = revision
+	}
+	if !rolledOut {
+		deployment.Status.AvailableReplicas = 0
+	}
+	return deployment
+}
+
+// crashLoopingPod is a pod of the named deployment running revision in CrashLoopBackOff
+func crashLoopingPod(name, revision string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name + "-0",
+			Labels:      map[string]string{"app": name},
+			Annotations: map[string]string{annotationRevision: revision},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}},
+	}
+}
+
+func TestPlanRollout(t *testing.T) {
+	now := time.Now()
+	justNow := metav1.NewTime(now.Add(-30 * time.Second))
+	longAgo := metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name        string
+		strategy    tracingv1.RolloutStrategy
+		objects     []client.Object
+		lastBatchAt *metav1.Time
+		phase       string
+		admitted    int
+	}{
+		{
+			name:     "first batch",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 2},
+			objects: []client.Object{
+				rolloutDeployment("a", "", true), rolloutDeployment("b", "", true), rolloutDeployment("c", "", true),
+				rolloutDeployment("d", "", true), rolloutDeployment("e", "", true),
+			},
+			phase: rolloutPhaseProgressing, admitted: 2,
+		},
+		{
+			name:     "batch percentage rounds up",
+			strategy: tracingv1.RolloutStrategy{BatchPercentage: 50},
+			objects: []client.Object{
+				rolloutDeployment("a", "", true), rolloutDeployment("b", "", true), rolloutDeployment("c", "", true),
+			},
+			phase: rolloutPhaseProgressing, admitted: 2,
+		},
+		{
+			name:     "last batch is smaller",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 2},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), rolloutDeployment("b", "rev-2", true), rolloutDeployment("c", "", true),
+			},
+			lastBatchAt: &longAgo,
+			phase:       rolloutPhaseProgressing, admitted: 1,
+		},
+		{
+			name:     "waits for the batch to become available",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 2},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), rolloutDeployment("b", "rev-2", false), rolloutDeployment("c", "", true),
+			},
+			lastBatchAt: &longAgo,
+			phase:       rolloutPhaseProgressing,
+		},
+		{
+			name:     "pauses between batches",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 1, Pause: "1m"},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), rolloutDeployment("b", "", true),
+			},
+			lastBatchAt: &justNow,
+			phase:       rolloutPhasePaused,
+		},
+		{
+			name:     "pause elapsed",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 1, Pause: "1m"},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), rolloutDeployment("b", "", true),
+			},
+			lastBatchAt: &longAgo,
+			phase:       rolloutPhaseProgressing, admitted: 1,
+		},
+		{
+			name:     "halts on an unhealthy batch",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 1, HaltOnCrashLoop: true},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", false), crashLoopingPod("a", "rev-2"), rolloutDeployment("b", "", true),
+			},
+			lastBatchAt: &longAgo,
+			phase:       rolloutPhaseHalted,
+		},
+		{
+			name:     "crash loops of the previous revision are ignored",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 1, HaltOnCrashLoop: true},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), crashLoopingPod("a", "rev-1"), rolloutDeployment("b", "", true),
+			},
+			lastBatchAt: &longAgo,
+			phase:       rolloutPhaseProgressing, admitted: 1,
+		},
+		{
+			name:     "complete",
+			strategy: tracingv1.RolloutStrategy{BatchSize: 1},
+			objects: []client.Object{
+				rolloutDeployment("a", "rev-2", true), rolloutDeployment("b", "rev-2", true),
+			},
+			phase: rolloutPhaseComplete,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := corev1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := appsv1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			r := &TracingConfigReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()}
+			tracingConfig := &tracingv1.TracingConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"},
+				Spec:       tracingv1.TracingConfigSpec{RolloutStrategy: &tt.strategy},
+			}
+			if tt.lastBatchAt != nil {
+				tracingConfig.Status.Rollout = &tracingv1.RolloutStatus{Revision: "rev-2", LastBatchAt: tt.lastBatchAt}
+			}
+
+			plan, err := r.planRollout(context.Background(), tracingConfig, []string{"default"}, labels.Everything(), "rev-2", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.status.Phase != tt.phase {
+				t.Errorf("phase = %s (%s), want %s", plan.status.Phase, plan.status.Message, tt.phase)
+			}
+
+			// Workloads already on the revision are always admitted, the others up to the batch size
+			admitted := 0
+			for _, object := range tt.objects {
+				deployment, ok := object.(*appsv1.Deployment)
+				if !ok {
+					continue
+				}
+				updated := deployment.Spec.Template.Annotations[annotationRevision] == "rev-2"
+				switch {
+				case !plan.admit(deployment):
+					if updated {
+						t.Errorf("admit(%s) = false for a workload on the revision", deployment.Name)
+					}
+				case !updated:
+					admitted++
+				}
+			}
+			if admitted != tt.admitted {
+				t.Errorf("admitted %d workloads, want %d", admitted, tt.admitted)
+			}
+			if tt.admitted > 0 && !plan.status.LastBatchAt.Equal(&plan.now) {
+				t.Errorf("last batch at %v, want the batch recorded at %v", plan.status.LastBatchAt, plan.now)
+			}
+		})
+	}
+}
+
+func TestPlanRolloutInvalidStrategy(t *testing.T) {
+	r := &TracingConfigReconciler{}
+	for _, strategy := range []tracingv1.RolloutStrategy{{Pause: "soon"}, {BatchSize: -1}, {BatchPercentage: 150}} {
+		strategy := strategy
+		tracingConfig := &tracingv1.TracingConfig{Spec: tracingv1.TracingConfigSpec{RolloutStrategy: &strategy}}
+		if _, err := r.planRollout(context.Background(), tracingConfig, nil, labels.Everything(), "rev-2", time.Now()); errorReason(err) != "InvalidRolloutStrategy" {
+			t.Errorf("planRollout(%+v) = %v, want InvalidRolloutStrategy", strategy, err)
+		}
+	}
+}
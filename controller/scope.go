@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+// parseWatchNamespaces reads the comma-separated WATCH_NAMESPACES list. An empty list means the
+// controller runs cluster-wide.
+func parseWatchNamespaces(value string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(value, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// namespaced reports whether the controller is restricted to WatchNamespaces
+func (r *TracingConfigReconciler) namespaced() bool {
+	return len(r.WatchNamespaces) > 0
+}
+
+// watches reports whether the controller can read and write in namespace
+func (r *TracingConfigReconciler) watches(namespace string) bool {
+	if !r.namespaced() {
+		return true
+	}
+	for _, watched := range r.WatchNamespaces {
+		if watched == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// splitWatched separates the target namespaces the controller has access to from the others,
+// which are reported as failures
+func (r *TracingConfigReconciler) splitWatched(namespaces []string) (watched []string, failures []tracingv1.WorkloadFailure) {
+	for _, namespace := range namespaces {
+		if r.watches(namespace) {
+			watched = append(watched, namespace)
+			continue
+		}
+		failures = append(failures, tracingv1.WorkloadFailure{
+			Kind:    "Namespace",
+			Name:    namespace,
+			Reason:  "NamespaceNotWatched",
+			Message: fmt.Sprintf("the controller only watches namespaces %s", strings.Join(r.WatchNamespaces, ", ")),
+		})
+	}
+	return watched, failures
+}
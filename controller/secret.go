@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// annotationSecret records the rendered Secret a pod template reads the sensitive variables from
+const annotationSecret = "tracing.kubevishwa.io/secret"
+
+// sourceSecretReader returns the reader for the Secrets users reference. The cache only holds
+// the Secrets we render, so that we never cache the data of every Secret in the cluster; the
+// others are only watched by their metadata.
+func (r *TracingConfigReconciler) sourceSecretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// readSourceSecrets returns the headers read from the headers Secret, and the contents of every
+// Secret the workloads get material from, keyed by Secret and key
+func (r *TracingConfigReconciler) readSourceSecrets(ctx context.Context, namespace string, spec *tracingv1.TracingConfigSpec) (headers, contents map[string]string, err error) {
+	names := map[string]bool{}
+	if spec.HeadersSecretName != "" {
+		names[spec.HeadersSecretName] = true
+	}
+	if tls := spec.TLS; tls != nil && !tls.Insecure {
+		if tls.CASecretName != "" {
+			names[tls.CASecretName] = true
+		}
+		if tls.ClientCertSecretName != "" {
+			names[tls.ClientCertSecretName] = true
+		}
+	}
+
+	contents = map[string]string{}
+	for name := range names {
+		var secret corev1.Secret
+		if err := r.sourceSecretReader().Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+			return nil, nil, fmt.Errorf("failed to get Secret %s/%s: %w", namespace, name, err)
+		}
+		for key, value := range secret.Data {
+			contents[name+"/"+key] = string(value)
+			if name == spec.HeadersSecretName {
+				if headers == nil {
+					headers = map[string]string{}
+				}
+				headers[key] = string(value)
+			}
+		}
+	}
+	return headers, contents, nil
+}
+
+// diffSecretData describes which keys of the live Secret differ from the desired data, without
+// revealing their values
+func diffSecretData(live map[string][]byte, desired map[string]string) []string {
+	var diff []string
+	for key, want := range desired {
+		got, ok := live[key]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("-%s", key))
+		case string(got) != want:
+			diff = append(diff, fmt.Sprintf("%s: value changed", key))
+		}
+	}
+	for key := range live {
+		if _, ok := desired[key]; !ok {
+			diff = append(diff, fmt.Sprintf("+%s", key))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// syncSecret creates or updates the Secret holding the sensitive variables, or deletes it when
+// there are none. Drift is reported like for the ConfigMap, naming only the keys.
+func (r *TracingConfigReconciler) syncSecret(ctx context.Context, namespace, name string, secretLabels, data map[string]string, enforce bool) (drift []string, err error) {
+	ctx, span := startSpan(ctx, "SyncSecret",
+		semconv.K8SNamespaceNameKey.String(namespace),
+		attribute.String("k8s.secret.name", name),
+	)
+	defer func() { endSpan(span, err) }()
+
+	existing := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get Secret: %w", err)
+	}
+	found := err == nil
+
+	if len(data) == 0 {
+		if !found {
+			return nil, nil
+		}
+		if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete Secret: %w", err)
+		}
+		span.SetAttributes(attribute.String("k8s.operation", "delete"))
+		log.Printf("Deleted Secret %s/%s, no sensitive settings are left", namespace, name)
+		return nil, nil
+	}
+
+	if !found {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Labels:      secretLabels,
+				Annotations: map[string]string{tracingv1.AnnotationConfigHash: render.ConfigHash(data)},
+			},
+			Type:       corev1.SecretTypeOpaque,
+			StringData: data,
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return nil, fmt.Errorf("failed to create Secret: %w", err)
+		}
+		span.SetAttributes(attribute.String("k8s.operation", "create"))
+		log.Printf("Created Secret %s/%s", namespace, name)
+		return nil, nil
+	}
+
+	live := make(map[string]string, len(existing.Data))
+	for key, value := range existing.Data {
+		live[key] = string(value)
+	}
+	// As for the ConfigMap, a spec change is applied over hand edits
+	if written := existing.Annotations[tracingv1.AnnotationConfigHash]; written != "" && written != render.ConfigHash(live) {
+		span.SetAttributes(attribute.Bool("drift.detected", true))
+		if written != render.ConfigHash(data) && !enforce {
+			log.Printf("Replacing hand edits to Secret %s/%s with the changed spec", namespace, name)
+		} else {
+			drift = diffSecretData(existing.Data, data)
+			if !enforce {
+				return drift, nil
+			}
+		}
+	} else if render.ConfigHash(live) == render.ConfigHash(data) {
+		return nil, nil
+	}
+
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for key, value := range secretLabels {
+		existing.Labels[key] = value
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[tracingv1.AnnotationConfigHash] = render.ConfigHash(data)
+	existing.Data = nil
+	existing.StringData = data
+	if err := r.Update(ctx, existing); err != nil {
+		return drift, fmt.Errorf("failed to update Secret: %w", err)
+	}
+	span.SetAttributes(attribute.String("k8s.operation", "update"))
+	log.Printf("Updated Secret %s/%s", namespace, name)
+	return drift, nil
+}
+
+// ensureSecretEnv points the containers matched by targets at the rendered Secret, or removes
+// the reference once there is no Secret. It reports whether the template was modified.
+func ensureSecretEnv(template *corev1.PodTemplateSpec, secretName string, targets func(container string) bool) bool {
+	current, injected := template.Annotations[annotationSecret]
+	if secretName == "" && !injected {
+		return false
+	}
+
+	updated := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		want := secretName != "" && targets(container.Name)
+		found := false
+		for j := 0; j < len(container.EnvFrom); j++ {
+			ref := container.EnvFrom[j].SecretRef
+			if ref == nil || (ref.Name != current && ref.Name != secretName) {
+				continue
+			}
+			if want && ref.Name == secretName && !found {
+				found = true
+				continue
+			}
+			container.EnvFrom = append(container.EnvFrom[:j], container.EnvFrom[j+1:]...)
+			j--
+			updated = true
+		}
+		// Optional, so that pods still start while the Secret is being cleaned up
+		if want && !found {
+			optional := true
+			container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Optional:             &optional,
+				},
+			})
+			updated = true
+		}
+	}
+
+	switch {
+	case secretName == "":
+		delete(template.Annotations, annotationSecret)
+		updated = true
+	case current != secretName:
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[annotationSecret] = secretName
+		updated = true
+	}
+	return updated
+}
+
+// tracingConfigsForSecret maps a change to a rendered Secret back to its TracingConfig
+func (r *TracingConfigReconciler) tracingConfigsForSecret(obj client.Object) []reconcile.Request {
+	return r.tracingConfigsForConfigMap(obj)
+}
+
+// secretMetadata is the metadata-only form of a Secret the source Secrets are watched as
+func secretMetadata() *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}}
+}
+
+// tracingConfigsForSourceSecret maps a change to a Secret the workloads read from to the
+// TracingConfigs referencing it, so that rotated credentials reach the pods
+func (r *TracingConfigReconciler) tracingConfigsForSourceSecret(obj client.Object) []reconcile.Request {
+	// Rendered Secrets are watched in full
+	if obj.GetLabels()[labelManagedBy] == managedByValue {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, request := range r.tracingConfigsTargeting(context.Background(), obj.GetNamespace()) {
+		var tracingConfig tracingv1.TracingConfig
+		if err := r.Get(context.Background(), request.NamespacedName, &tracingConfig); err != nil {
+			continue
+		}
+		spec := &tracingConfig.Spec
+		if tracingConfig.Status.ResolvedSpec != nil {
+			spec = tracingConfig.Status.ResolvedSpec
+		}
+		if referencesSecret(spec, obj.GetName()) {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// referencesSecret reports whether the workloads read material from the named Secret
+func referencesSecret(spec *tracingv1.TracingConfigSpec, name string) bool {
+	if spec.HeadersSecretName == name {
+		return true
+	}
+	tls := spec.TLS
+	return tls != nil && !tls.Insecure && (tls.CASecretName == name || tls.ClientCertSecretName == name)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// selectDeployments returns the deployments in namespace the TracingConfig injects: those matching
+// its selector or opting in through their annotations, minus those opting out. The ones left
+// alone because of their annotations are returned as skipped. It only reads.
+func (r *TracingConfigReconciler) selectDeployments(ctx context.Context, tracingConfig *tracingv1.TracingConfig, namespace string, selector labels.Selector) ([]appsv1.Deployment, []tracingv1.SkippedWorkload, error) {
+	var deployments appsv1.DeploymentList
+	if err := r.List(ctx, &deployments, client.InNamespace(namespace)); err != nil {
+		return nil, nil, fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+	}
+
+	var selected []appsv1.Deployment
+	var skipped []tracingv1.SkippedWorkload
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		ok, skip := render.SelectWorkload(tracingConfig, selector, deployment)
+		if ok {
+			selected = append(selected, *deployment)
+			continue
+		}
+		if skip != nil {
+			skipped = append(skipped, *skip)
+		}
+	}
+	return selected, skipped, nil
+}
+
+// releaseSkipped removes whatever the TracingConfig injected before from the deployments its
+// selection skips. Unless the TracingConfig enforces drift, the injection is left in place and
+// reported as drift.
+func (r *TracingConfigReconciler) releaseSkipped(ctx context.Context, tracingConfig *tracingv1.TracingConfig, skipped []tracingv1.SkippedWorkload) ([]tracingv1.DriftReport, []namespaceFailure) {
+	baseConfigMapName := render.ConfigMapName(tracingConfig)
+	var drift []tracingv1.DriftReport
+	var failures []namespaceFailure
+	for _, skip := range skipped {
+		deployment := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: skip.Namespace, Name: skip.Name}, deployment); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				failures = append(failures, newNamespaceFailure("Deployment", skip.Namespace, skip.Name, fmt.Errorf("failed to get deployment: %w", err)))
+			}
+			continue
+		}
+		if !removeInjection(&deployment.Spec.Template, func(name string) bool { return render.IsTracingConfigMap(name, baseConfigMapName) }) {
+			continue
+		}
+		if !tracingConfig.Spec.EnforcesDrift() {
+			drift = append(drift, tracingv1.DriftReport{Kind: "Deployment", Namespace: skip.Namespace, Name: skip.Name,
+				Diff: []string{fmt.Sprintf("still injected although skipped: %s", skip.Message)}})
+			continue
+		}
+		if err := r.Update(ctx, deployment); err != nil {
+			failures = append(failures, newNamespaceFailure("Deployment", skip.Namespace, skip.Name,
+				fmt.Errorf("failed to remove tracing configuration: %w", err)))
+			continue
+		}
+		log.Printf("Removed tracing configuration from deployment %s/%s: %s", skip.Namespace, skip.Name, skip.Message)
+	}
+	return drift, failures
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationServiceName records the service name rendered onto a pod template from a serviceName template
+	annotationServiceName = "tracing.kubevishwa.io/service-name"
+
+	// annotationInjectedEnv lists, as container/NAME pairs, the explicit env vars we set on the
+	// containers of a pod template, so that only those are ever removed again
+	annotationInjectedEnv = "tracing.kubevishwa.io/injected-env"
+)
+
+// ensureServiceName sets the OTEL_SERVICE_NAME env var rendered for this workload on the
+// containers matched by targets, or removes it once the service name is no longer templated. It
+// reports whether the template was modified.
+func ensureServiceName(template *corev1.PodTemplateSpec, serviceName string, targets func(container string) bool) bool {
+	current, injected := template.Annotations[annotationServiceName]
+	if serviceName == "" && !injected {
+		return false
+	}
+
+	updated := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if serviceName == "" || !targets(container.Name) {
+			if unsetInjectedEnv(template, container, "OTEL_SERVICE_NAME") {
+				updated = true
+			}
+			continue
+		}
+		if setInjectedEnv(template, container, corev1.EnvVar{Name: "OTEL_SERVICE_NAME", Value: serviceName}) {
+			updated = true
+		}
+	}
+
+	switch {
+	case serviceName == "":
+		delete(template.Annotations, annotationServiceName)
+		updated = true
+	case current != serviceName:
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[annotationServiceName] = serviceName
+		updated = true
+	}
+	return updated
+}
+
+// removeEnv removes the env var from the container, reporting whether it was set
+func removeEnv(container *corev1.Container, name string) bool {
+	for i, env := range container.Env {
+		if env.Name == name {
+			container.Env = append(container.Env[:i], container.Env[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// injectedEnv returns the container/NAME pairs of the env vars we set, as recorded on the pod template
+func injectedEnv(template *corev1.PodTemplateSpec) map[string]bool {
+	injected := map[string]bool{}
+	for _, key := range strings.Split(template.Annotations[annotationInjectedEnv], ",") {
+		if key != "" {
+			injected[key] = true
+		}
+	}
+	return injected
+}
+
+// markInjectedEnv records on the pod template whether we set the env var on the container,
+// reporting whether the annotation changed
+func markInjectedEnv(template *corev1.PodTemplateSpec, container, name string, set bool) bool {
+	injected := injectedEnv(template)
+	key := container + "/" + name
+	if injected[key] == set {
+		return false
+	}
+	if set {
+		injected[key] = true
+	} else {
+		delete(injected, key)
+	}
+
+	if len(injected) == 0 {
+		delete(template.Annotations, annotationInjectedEnv)
+		return true
+	}
+	keys := make([]string, 0, len(injected))
+	for key := range injected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[annotationInjectedEnv] = strings.Join(keys, ",")
+	return true
+}
+
+// setInjectedEnv sets the env var on the container and records that we did, reporting whether
+// the template was modified
+func setInjectedEnv(template *corev1.PodTemplateSpec, container *corev1.Container, env corev1.EnvVar) bool {
+	updated := markInjectedEnv(template, container.Name, env.Name, true)
+	for j := range container.Env {
+		if container.Env[j].Name != env.Name {
+			continue
+		}
+		if container.Env[j].Value != env.Value || container.Env[j].ValueFrom != nil {
+			container.Env[j] = env
+			updated = true
+		}
+		return updated
+	}
+	container.Env = append(container.Env, env)
+	return true
+}
+
+// unsetInjectedEnv removes the env var from the container if we set it, leaving one the
+// container sets itself alone. It reports whether the template was modified.
+func unsetInjectedEnv(template *corev1.PodTemplateSpec, container *corev1.Container, name string) bool {
+	if !markInjectedEnv(template, container.Name, name, false) {
+		return false
+	}
+	removeEnv(container, name)
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+// workloadStatus summarizes how far the current configuration has reached the pods of a deployment.
+// Containers count as injected when they refer to any revision of the ConfigMap.
+func (r *TracingConfigReconciler) workloadStatus(ctx context.Context, deployment *appsv1.Deployment, configMapName, hash string) (tracingv1.WorkloadStatus, error) {
+	status := tracingv1.WorkloadStatus{
+		Kind:       "Deployment",
+		Namespace:  deployment.Namespace,
+		Name:       deployment.Name,
+		ConfigHash: hash,
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil && render.IsTracingConfigMap(envFrom.ConfigMapRef.Name, configMapName) {
+				status.InjectedContainers = append(status.InjectedContainers, container.Name)
+				break
+			}
+		}
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return status, fmt.Errorf("invalid selector on deployment %s: %w", deployment.Name, err)
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return status, fmt.Errorf("failed to list pods of deployment %s: %w", deployment.Name, err)
+	}
+
+	observed := map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		podHash := pod.Annotations[tracingv1.AnnotationConfigHash]
+		if podHash != "" {
+			observed[podHash] = true
+		}
+		switch {
+		case podHash != hash:
+			status.StalePods++
+		case isPodReady(pod):
+			status.ReadyPods++
+		}
+	}
+	for observedHash := range observed {
+		status.ObservedConfigHashes = append(status.ObservedConfigHashes, observedHash)
+	}
+	sort.Strings(status.ObservedConfigHashes)
+
+	return status, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
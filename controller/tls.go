@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	tlsCAVolumeName     = "otel-exporter-ca"
+	tlsClientVolumeName = "otel-exporter-client-cert"
+)
+
+// validateTLSSecrets makes sure the referenced Secrets exist and carry the expected keys,
+// so that we never roll out a pod template whose volumes cannot be mounted
+func (r *TracingConfigReconciler) validateTLSSecrets(ctx context.Context, namespace string, tls *tracingv1.TracingTLSConfig) error {
+	if tls == nil || tls.Insecure {
+		return nil
+	}
+
+	if tls.CASecretName != "" {
+		if err := r.checkSecretKeys(ctx, namespace, tls.CASecretName, tls.CASecretKey()); err != nil {
+			return err
+		}
+	}
+	if tls.ClientCertSecretName != "" {
+		if err := r.checkSecretKeys(ctx, namespace, tls.ClientCertSecretName, corev1.TLSCertKey, corev1.TLSPrivateKeyKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSecretKeys reads the Secret around the cache, which only holds the Secrets we render
+func (r *TracingConfigReconciler) checkSecretKeys(ctx context.Context, namespace, name string, keys ...string) error {
+	var secret corev1.Secret
+	if err := r.sourceSecretReader().Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &secret); err != nil {
+		return fmt.Errorf("failed to get Secret %s/%s: %w", namespace, name, err)
+	}
+	for _, key := range keys {
+		if _, ok := secret.Data[key]; !ok {
+			return terminal("InvalidTLSSecret", fmt.Errorf("Secret %s/%s is missing key %q", namespace, name, key))
+		}
+	}
+	return nil
+}
+
+// ensureTLSVolumes mounts the CA bundle and client certificate Secrets into the containers
+// matched by targets, or removes the mounts again once TLS is no longer configured.
+// It reports whether the pod spec was modified.
+func ensureTLSVolumes(podSpec *corev1.PodSpec, tls *tracingv1.TracingTLSConfig, targets func(container string) bool) bool {
+	var caSecret, clientSecret string
+	if tls != nil && !tls.Insecure {
+		caSecret = tls.CASecretName
+		clientSecret = tls.ClientCertSecretName
+	}
+
+	updated := ensureSecretVolume(podSpec, tlsCAVolumeName, render.TLSCAMountPath, caSecret, targets)
+	if ensureSecretVolume(podSpec, tlsClientVolumeName, render.TLSClientMountPath, clientSecret, targets) {
+		updated = true
+	}
+	return updated
+}
+
+// ensureSecretVolume adds (or, when secretName is empty, removes) a read-only Secret volume
+// and the matching mount on the containers matched by targets
+func ensureSecretVolume(podSpec *corev1.PodSpec, volumeName, mountPath, secretName string, targets func(container string) bool) bool {
+	updated := false
+
+	volumeIndex := -1
+	for i, volume := range podSpec.Volumes {
+		if volume.Name == volumeName {
+			volumeIndex = i
+			break
+		}
+	}
+
+	if secretName == "" {
+		if volumeIndex >= 0 {
+			podSpec.Volumes = append(podSpec.Volumes[:volumeIndex], podSpec.Volumes[volumeIndex+1:]...)
+			updated = true
+		}
+		for i := range podSpec.Containers {
+			if removeVolumeMount(&podSpec.Containers[i], volumeName) {
+				updated = true
+			}
+		}
+		return updated
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+		},
+	}
+	if volumeIndex < 0 {
+		podSpec.Volumes = append(podSpec.Volumes, volume)
+		updated = true
+	} else if existing := podSpec.Volumes[volumeIndex].Secret; existing == nil || existing.SecretName != secretName {
+		podSpec.Volumes[volumeIndex] = volume
+		updated = true
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if !targets(container.Name) {
+			if removeVolumeMount(container, volumeName) {
+				updated = true
+			}
+			continue
+		}
+		mountExists := false
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == volumeName {
+				mountExists = true
+				break
+			}
+		}
+		if !mountExists {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      volumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+			updated = true
+		}
+	}
+	return updated
+}
+
+// removeVolumeMount removes the mount of volumeName from the container, reporting whether there was one
+func removeVolumeMount(container *corev1.Container, volumeName string) bool {
+	for i, mount := range container.VolumeMounts {
+		if mount.Name == volumeName {
+			container.VolumeMounts = append(container.VolumeMounts[:i], container.VolumeMounts[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
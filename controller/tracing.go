@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+
+	tracingv1 "tracing-controller/api/v1"
+)
+
+// tracer is used for the controller's own spans. It delegates to the global provider,
+// so it is a no-op until initTracer installs an exporting one.
+var tracer = otel.Tracer("tracing-controller")
+
+// initTracer sets up self-tracing of the controller. It reads the standard OTEL_* variables
+// and stays disabled unless OTEL_EXPORTER_OTLP_ENDPOINT is set.
+func initTracer(ctx context.Context) func() {
+	otlpEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if otlpEndpoint == "" {
+		log.Println("Self-tracing disabled: OTEL_EXPORTER_OTLP_ENDPOINT is not set")
+		return func() {}
+	}
+
+	exporterOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(otlpEndpoint)}
+	if insecure, err := strconv.ParseBool(os.Getenv("OTEL_EXPORTER_OTLP_INSECURE")); err != nil || insecure {
+		exporterOptions = append(exporterOptions, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, exporterOptions...)
+	if err != nil {
+		log.Printf("Failed to create OTLP exporter, self-tracing disabled: %v", err)
+		return func() {}
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "tracing-controller"
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.K8SNamespaceNameKey.String(os.Getenv("NAMESPACE")),
+		),
+	)
+	if err != nil {
+		log.Printf("Failed to create resource: %v", err)
+		res = resource.Default()
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if samplerArg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); samplerArg != "" {
+		if ratio, err := strconv.ParseFloat(samplerArg, 64); err == nil {
+			sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	log.Printf("Self-tracing enabled, exporting to %s", otlpEndpoint)
+
+	return func() {
+		if err := tp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down tracer provider: %v", err)
+		}
+	}
+}
+
+// instrumentRESTConfig traces every request made to the API server, which makes
+// client-side throttling and slow API calls visible under the reconcile spans
+func instrumentRESTConfig(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt)
+	})
+}
+
+// startSpan starts a child span of the reconcile for one step of it
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingConfigAttributes identifies the TracingConfig being reconciled
+func tracingConfigAttributes(tracingConfig *tracingv1.TracingConfig) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.K8SNamespaceNameKey.String(tracingConfig.Namespace),
+		attribute.String("tracingconfig.name", tracingConfig.Name),
+		attribute.Int64("tracingconfig.generation", tracingConfig.Generation),
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tracingv1 "tracing-controller/api/v1"
+	"tracing-controller/render"
+)
+
+const (
+	// validateTracingConfigPath is where the ValidatingWebhookConfiguration sends TracingConfigs
+	validateTracingConfigPath = "/validate-tracingconfig"
+	// validateDebugSessionPath is where it sends TracingDebugSessions
+	validateDebugSessionPath = "/validate-tracingdebugsession"
+	// mutateDebugSessionPath is where the MutatingWebhookConfiguration sends TracingDebugSessions
+	mutateDebugSessionPath = "/mutate-tracingdebugsession"
+)
+
+// setupWebhook serves the admission webhooks from the manager, with the serving certificate
+// read from WEBHOOK_CERT_DIR if set
+func setupWebhook(mgr manager.Manager, reconciler *TracingConfigReconciler) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return fmt.Errorf("failed to create admission decoder: %w", err)
+	}
+	server := mgr.GetWebhookServer()
+	if certDir := os.Getenv("WEBHOOK_CERT_DIR"); certDir != "" {
+		server.CertDir = certDir
+	}
+	server.Register(validateTracingConfigPath, &webhook.Admission{
+		Handler: &tracingConfigValidator{reconciler: reconciler, decoder: decoder},
+	})
+	server.Register(validateDebugSessionPath, &webhook.Admission{
+		Handler: &debugSessionValidator{reconciler: reconciler, decoder: decoder},
+	})
+	server.Register(mutateDebugSessionPath, &webhook.Admission{
+		Handler: &debugSessionRequester{decoder: decoder},
+	})
+	return nil
+}
+
+// tracingConfigValidator refuses TracingConfigs that a TracingPolicy rejects before they are
+// stored, and warns about the settings the controller will clamp. It checks the spec the way the
+// reconciler renders it, with the namespace's TracingDefaults merged in.
+type tracingConfigValidator struct {
+	reconciler *TracingConfigReconciler
+	decoder    *admission.Decoder
+}
+
+func (v *tracingConfigValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctx, span := startSpan(ctx, "ValidateTracingConfig")
+	defer endSpan(span, nil)
+
+	var tracingConfig tracingv1.TracingConfig
+	if err := v.decoder.Decode(req, &tracingConfig); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if tracingConfig.Namespace == "" {
+		tracingConfig.Namespace = req.Namespace
+	}
+
+	// Let a TracingConfig that is going away through
+	r := v.reconciler
+	if !tracingConfig.DeletionTimestamp.IsZero() || !r.watches(tracingConfig.Namespace) {
+		return admission.Allowed("")
+	}
+	// Metadata updates, like the controller adding its finalizer, leave the spec alone. So does a
+	// request for a rollback that changes nothing else; the restored spec is checked when the
+	// controller writes it.
+	if req.Operation == admissionv1.Update {
+		var old tracingv1.TracingConfig
+		if err := v.decoder.DecodeRaw(req.OldObject, &old); err == nil {
+			oldSpec, newSpec := old.Spec, tracingConfig.Spec
+			oldSpec.RollbackTo, newSpec.RollbackTo = nil, nil
+			if equality.Semantic.DeepEqual(oldSpec, newSpec) {
+				return admission.Allowed("")
+			}
+		}
+	}
+
+	if err := checkLegacyNamespace(&tracingConfig); err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	var defaults tracingv1.TracingDefaultsList
+	if err := r.List(ctx, &defaults, client.InNamespace(tracingConfig.Namespace)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to list tracing defaults: %w", err))
+	}
+	tracingConfig.Spec, _ = render.ApplyDefaults(tracingConfig.Spec, defaults.Items)
+
+	// A spec whose targets cannot be resolved is reported by the controller
+	namespaces, err := r.resolveTargetNamespaces(ctx, &tracingConfig)
+	if err != nil {
+		return admission.Allowed("").WithWarnings(fmt.Sprintf("TracingPolicies not checked: %v", err))
+	}
+	namespaces, _ = r.splitWatched(namespaces)
+
+	policies, err := r.policiesFor(ctx, namespaces)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	evaluation := render.EvaluatePolicies(tracingConfig.Spec, policies)
+	if evaluation.Rejected() {
+		return admission.Denied(render.DescribeViolations(evaluation.RejectedViolations()))
+	}
+	var warnings []string
+	for _, violation := range evaluation.Violations {
+		warnings = append(warnings, fmt.Sprintf("%s: %s, clamped by TracingPolicy %s", violation.Field, violation.Message, violation.Policy))
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// debugSessionValidator refuses TracingDebugSessions whose sampling rate a Reject TracingPolicy
+// of their namespace caps, and warns when a Clamp policy will lower it
+type debugSessionValidator struct {
+	reconciler *TracingConfigReconciler
+	decoder    *admission.Decoder
+}
+
+func (v *debugSessionValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctx, span := startSpan(ctx, "ValidateTracingDebugSession")
+	defer endSpan(span, nil)
+
+	var session tracingv1.TracingDebugSession
+	if err := v.decoder.Decode(req, &session); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if session.Namespace == "" {
+		session.Namespace = req.Namespace
+	}
+
+	r := v.reconciler
+	if !session.DeletionTimestamp.IsZero() || !r.watches(session.Namespace) {
+		return admission.Allowed("")
+	}
+	if req.Operation == admissionv1.Update {
+		var old tracingv1.TracingDebugSession
+		if err := v.decoder.DecodeRaw(req.OldObject, &old); err == nil && equality.Semantic.DeepEqual(old.Spec, session.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	policies, err := r.policiesFor(ctx, []string{session.Namespace})
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	var rejected []tracingv1.PolicyViolation
+	var warnings []string
+	for _, violation := range render.DebugSessionViolations(&session, policies) {
+		if violation.Outcome == tracingv1.PolicyViolationRejected {
+			rejected = append(rejected, violation)
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: %s, clamped by TracingPolicy %s", violation.Field, violation.Message, violation.Policy))
+	}
+	if len(rejected) > 0 {
+		return admission.Denied(render.DescribeViolations(rejected))
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// debugSessionRequester records the user that creates a TracingDebugSession from the admission
+// request, which unlike spec.requestedBy cannot be forged, and keeps the record from being changed
+type debugSessionRequester struct {
+	decoder *admission.Decoder
+}
+
+func (m *debugSessionRequester) Handle(ctx context.Context, req admission.Request) admission.Response {
+	_, span := startSpan(ctx, "RecordDebugSessionRequester")
+	defer endSpan(span, nil)
+
+	var session tracingv1.TracingDebugSession
+	if err := m.decoder.Decode(req, &session); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	requester, recorded := req.UserInfo.Username, true
+	if req.Operation == admissionv1.Update {
+		var old tracingv1.TracingDebugSession
+		if err := m.decoder.DecodeRaw(req.OldObject, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		requester, recorded = old.Annotations[tracingv1.AnnotationRequestedBy]
+	}
+	if current, ok := session.Annotations[tracingv1.AnnotationRequestedBy]; current == requester && ok == recorded {
+		return admission.Allowed("")
+	}
+
+	if recorded {
+		if session.Annotations == nil {
+			session.Annotations = map[string]string{}
+		}
+		session.Annotations[tracingv1.AnnotationRequestedBy] = requester
+	} else {
+		delete(session.Annotations, tracingv1.AnnotationRequestedBy)
+	}
+	marshaled, err := json.Marshal(&session)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"tracing-controller/otlp"
+)
+
+const (
+	protocolGRPC         = "grpc"
+	protocolHTTPProtobuf = "http/protobuf"
+
+	defaultGRPCEndpoint = "otel-collector.observability.svc.cluster.local:4317"
+	defaultHTTPEndpoint = "otel-collector.observability.svc.cluster.local:4318"
+)
+
+// exporterSettings holds the transport-independent settings read from OTEL_EXPORTER_OTLP_*
+type exporterSettings struct {
+	Protocol    string
+	Endpoint    string
+	Timeout     time.Duration
+	Compression string
+	TLS         *tls.Config
+}
+
+// newOTLPExporter creates the span exporter for the configured OTLP protocol
+func newOTLPExporter(ctx context.Context, settings exporterSettings) (sdktrace.SpanExporter, error) {
+	target, err := otlp.ParseEndpoint(settings.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// An explicit scheme on the endpoint takes precedence over OTEL_EXPORTER_OTLP_INSECURE
+	tlsConfig := settings.TLS
+	switch target.Scheme {
+	case "http":
+		tlsConfig = nil
+	case "https":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+	}
+
+	switch settings.Protocol {
+	case protocolGRPC:
+		options := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(target.Host),
+			otlptracegrpc.WithTimeout(settings.Timeout),
+		}
+		if tlsConfig != nil {
+			options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		} else {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		if settings.Compression == "gzip" {
+			options = append(options, otlptracegrpc.WithCompressor(gzip.Name))
+		}
+		return otlptracegrpc.New(ctx, options...)
+
+	case protocolHTTPProtobuf:
+		options := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(target.Host),
+			otlptracehttp.WithURLPath(target.Path),
+			otlptracehttp.WithTimeout(settings.Timeout),
+		}
+		if tlsConfig != nil {
+			options = append(options, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		} else {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		if settings.Compression == "gzip" {
+			options = append(options, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		} else {
+			options = append(options, otlptracehttp.WithCompression(otlptracehttp.NoCompression))
+		}
+		return otlptracehttp.New(ctx, options...)
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (expected %q or %q)", settings.Protocol, protocolGRPC, protocolHTTPProtobuf)
+	}
+}
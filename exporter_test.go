@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"tracing-controller/otlp"
+)
+
+func TestParseOTLPEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     otlp.Endpoint
+		wantErr  bool
+	}{
+		{name: "host:port", endpoint: "collector:4318", want: otlp.Endpoint{Host: "collector:4318", Path: "/v1/traces"}},
+		{name: "host:port/path", endpoint: "collector:4318/otel", want: otlp.Endpoint{Host: "collector:4318", Path: "/otel/v1/traces"}},
+		{name: "URL without a path", endpoint: "http://collector:4318", want: otlp.Endpoint{Host: "collector:4318", Path: "/v1/traces", Scheme: "http"}},
+		{name: "URL with /", endpoint: "https://collector:4318/", want: otlp.Endpoint{Host: "collector:4318", Path: "/v1/traces", Scheme: "https"}},
+		{name: "URL with a base path", endpoint: "http://gw:4318/otel", want: otlp.Endpoint{Host: "gw:4318", Path: "/otel/v1/traces", Scheme: "http"}},
+		{name: "URL without a host", endpoint: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := otlp.ParseEndpoint(tt.endpoint)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseEndpoint(%q) = %+v, want an error", tt.endpoint, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseEndpoint(%q) = %+v, %v, want %+v", tt.endpoint, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"math/rand"
 	"net/http"
@@ -13,7 +14,6 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -61,10 +61,30 @@ var orders = []Order{}
 func initTracer() func() {
 	ctx := context.Background()
 
+	// Tracing can be switched off without removing the rest of the configuration
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "kubevishwa-api"
+	}
+	if disabled, err := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); err == nil && disabled {
+		tracer = otel.Tracer(serviceName)
+		log.Printf("OpenTelemetry disabled by OTEL_SDK_DISABLED")
+		return func() {}
+	}
+
+	// Get OTLP protocol from environment variable
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	if protocol == "" {
+		protocol = protocolGRPC
+	}
+
 	// Get OTLP endpoint from environment variable
 	otlpEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 	if otlpEndpoint == "" {
-		otlpEndpoint = "otel-collector.observability.svc.cluster.local:4317"
+		otlpEndpoint = defaultGRPCEndpoint
+		if protocol == protocolHTTPProtobuf {
+			otlpEndpoint = defaultHTTPEndpoint
+		}
 	}
 
 	// Get timeout from environment variable
@@ -76,30 +96,37 @@ func initTracer() func() {
 		}
 	}
 
-	// Create OTLP exporter
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint(otlpEndpoint),
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithTimeout(timeout),
-	)
+	// Get transport security configuration from environment variables
+	tlsConfig, err := exporterTLSConfigFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to create OTLP exporter: %v", err)
+		log.Fatalf("Failed to load OTLP TLS configuration: %v", err)
 	}
 
-	// Get service name from environment variable
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "kubevishwa-api"
+	// Create OTLP exporter
+	exporter, err := newOTLPExporter(ctx, exporterSettings{
+		Protocol:    protocol,
+		Endpoint:    otlpEndpoint,
+		Timeout:     timeout,
+		Compression: os.Getenv("OTEL_EXPORTER_OTLP_COMPRESSION"),
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create OTLP exporter: %v", err)
 	}
 
-	// Create resource
+	// Create resource. OTEL_RESOURCE_ATTRIBUTES carries the k8s.* attributes the controller
+	// injects through the downward API and overrides the defaults below.
 	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceNameKey.String(serviceName),
 			semconv.ServiceVersionKey.String("1.0.0"),
 		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
 	)
-	if err != nil {
+	if errors.Is(err, resource.ErrPartialResource) {
+		log.Printf("Ignoring malformed resource attributes: %v", err)
+	} else if err != nil {
 		log.Fatalf("Failed to create resource: %v", err)
 	}
 
@@ -143,7 +170,8 @@ func initTracer() func() {
 
 	log.Printf("OpenTelemetry initialized successfully")
 	log.Printf("Service name: %s", serviceName)
-	log.Printf("OTLP endpoint: %s", otlpEndpoint)
+	log.Printf("OTLP endpoint: %s (%s)", otlpEndpoint, protocol)
+	log.Printf("OTLP TLS enabled: %t", tlsConfig != nil)
 	log.Printf("Sampling rate: %s", os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
 
 	return func() {
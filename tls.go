@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// exporterTLSConfigFromEnv builds the client TLS configuration for the OTLP exporter from
+// OTEL_EXPORTER_OTLP_CERTIFICATE, OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and
+// OTEL_EXPORTER_OTLP_CLIENT_KEY. A nil config means the connection should be insecure.
+func exporterTLSConfigFromEnv() (*tls.Config, error) {
+	caFile := os.Getenv("OTEL_EXPORTER_OTLP_CERTIFICATE")
+	certFile := os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE")
+	keyFile := os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_KEY")
+
+	// Plaintext stays the default unless TLS is asked for, either explicitly or by providing certificates
+	insecure := caFile == "" && certFile == ""
+	if insecureStr := os.Getenv("OTEL_EXPORTER_OTLP_INSECURE"); insecureStr != "" {
+		if value, err := strconv.ParseBool(insecureStr); err == nil {
+			insecure = value
+		}
+	}
+	if insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("both OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and OTEL_EXPORTER_OTLP_CLIENT_KEY must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}